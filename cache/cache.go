@@ -1,9 +1,12 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/armiariyan/bepkg/logger"
+	"github.com/bradfitz/gomemcache/memcache"
 )
 
 // Keyval key value interface
@@ -13,6 +16,202 @@ type Keyval interface {
 	Set(key string, val []byte, expiration time.Duration) error
 	Delete(key string) error
 	Get(key string) ([]byte, error)
+
+	//Batch operations, GetMulti omits keys which didn't exist in the cache
+	GetMulti(keys []string) (map[string][]byte, error)
+	SetMulti(items map[string][]byte, expiration time.Duration) error
+	DeleteMulti(keys []string) error
+
+	//Atomic counters, expiration is applied when the counter has no expiration yet
+	Incr(key string, delta int64, expiration time.Duration) (int64, error)
+	Decr(key string, delta int64, expiration time.Duration) (int64, error)
+	//TTL returns remaining time to live, 0 if the key didn't exist and NoExpiration if it never expires
+	TTL(key string) (time.Duration, error)
+	//Touch resets the expiration without rewriting the value, 0 removes the expiration
+	Touch(key string, expiration time.Duration) error
+	Exists(key string) (bool, error)
+}
+
+// NoExpiration returned by TTL for keys without expiration
+const NoExpiration time.Duration = -1
+
+// ErrNotSupported returned by backends which can't provide the operation
+var ErrNotSupported = errors.New("cache: operation not supported by backend")
+
+// KeyvalContext key value interface which respects cancellation and deadline of the context
+type KeyvalContext interface {
+	Keyval
+	AddCtx(ctx context.Context, key string, val []byte, expiration time.Duration) error
+	SetCtx(ctx context.Context, key string, val []byte, expiration time.Duration) error
+	DeleteCtx(ctx context.Context, key string) error
+	GetCtx(ctx context.Context, key string) ([]byte, error)
+}
+
+// ZMember member of sorted set with its score
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// PubSub publish subscribe messaging
+type PubSub interface {
+	Publish(channel string, payload []byte) error
+	//Subscribe calls handler for every message of the channels until ctx is done,
+	//the subscription survives reconnects and primary failover
+	Subscribe(ctx context.Context, channels []string, handler func(channel string, payload []byte)) error
+}
+
+// StreamMessage entry of a redis stream
+type StreamMessage struct {
+	ID     string
+	Fields map[string]string
+}
+
+// PendingMessage message delivered to a consumer of the group but not acknowledged yet
+type PendingMessage struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int64
+}
+
+// Streams redis streams with consumer groups
+type Streams interface {
+	//XAdd appends the message and returns its id, maxLen above 0 trims the stream approximately
+	XAdd(stream string, maxLen int64, fields map[string]string) (string, error)
+	//XGroupCreate creates the consumer group starting at id ("$" new messages only, "0" whole stream),
+	//the stream is created when missing and an existing group is not an error
+	XGroupCreate(stream, group, id string) error
+	//XReadGroup reads up to count new messages for consumer, blocking up to block when block is above 0.
+	//Return empty slice if no message arrived in time.
+	XReadGroup(ctx context.Context, stream, group, consumer string, count int, block time.Duration) ([]StreamMessage, error)
+	XAck(stream, group string, ids ...string) (int64, error)
+	XPending(stream, group string, count int) ([]PendingMessage, error)
+	//XClaim takes over pending messages idle for at least minIdle
+	XClaim(stream, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamMessage, error)
+	//XClaimStuck claims up to count pending messages of the group idle for at least minIdle
+	XClaimStuck(stream, group, consumer string, minIdle time.Duration, count int) ([]StreamMessage, error)
+}
+
+// PoolStats connection pool usage of one redis server
+type PoolStats struct {
+	Addr string
+	//Size configured pool size, Idle connections available in the pool
+	Size int
+	Idle int
+}
+
+// ClusterNode redis cluster node, PrimaryAddr is empty for primaries
+type ClusterNode struct {
+	Addr        string
+	ID          string
+	PrimaryAddr string
+	Slots       [][2]uint16
+}
+
+// RedisStats topology and pool usage of redis client
+type RedisStats struct {
+	Topology Topology
+	//Primary current primary, selected by sentinel for Sentinel topology
+	Primary   string
+	Replicas  []string
+	Sentinels []string
+	Nodes     []ClusterNode
+	Pools     []PoolStats
+}
+
+// ErrCASConflict returned by CompareAndSwap when the item changed since GetWithToken
+var ErrCASConflict = errors.New("cache: compare-and-swap conflict")
+
+// CASToken version of an item returned by GetWithToken
+type CASToken struct {
+	//memcache item carrying the cas id
+	item *memcache.Item
+	//redis value read, nil if the key didn't exist
+	val []byte
+}
+
+// CAS is implemented by caches supporting optimistic updates, e.g. redis and memcache clients
+type CAS interface {
+	//GetWithToken gets the item and its token, nil byte if the item didn't exist
+	GetWithToken(key string) ([]byte, CASToken, error)
+	//CompareAndSwap writes val only if the item didn't change since GetWithToken, ErrCASConflict
+	//otherwise. Token of a missing item writes only if the item still doesn't exist.
+	CompareAndSwap(key string, val []byte, expiration time.Duration, token CASToken) error
+}
+
+// RedisClient redis client with data structure commands besides key value
+type RedisClient interface {
+	KeyvalContext
+	Scripter
+	PubSub
+	Streams
+	Tagger
+	CAS
+
+	//Ping checks every primary responds, e.g. for readiness probe
+	Ping(ctx context.Context) error
+	//Close closes every pooled connection, the client is unusable afterwards
+	Close() error
+	Stats() RedisStats
+
+	//Hashes, HGet returns nil byte if the field didn't exist
+	HGet(key, field string) ([]byte, error)
+	HSet(key string, fields map[string][]byte) error
+	HGetAll(key string) (map[string][]byte, error)
+	HDel(key string, fields ...string) error
+
+	//Lists, LPop and RPop return nil byte if the list is empty
+	LPush(key string, vals ...[]byte) (int64, error)
+	RPush(key string, vals ...[]byte) (int64, error)
+	LPop(key string) ([]byte, error)
+	RPop(key string) ([]byte, error)
+	LRange(key string, start, stop int) ([][]byte, error)
+	LLen(key string) (int64, error)
+
+	//Sets
+	SAdd(key string, members ...string) (int64, error)
+	SRem(key string, members ...string) (int64, error)
+	SMembers(key string) ([]string, error)
+	SIsMember(key, member string) (bool, error)
+
+	//Sorted sets, min and max accept redis score syntax e.g. "-inf", "(10"
+	ZAdd(key string, members ...ZMember) (int64, error)
+	ZRem(key string, members ...string) (int64, error)
+	ZIncrBy(key string, delta float64, member string) (float64, error)
+	ZScore(key, member string) (score float64, exists bool, err error)
+	ZRangeByScore(key, min, max string, offset, count int) ([]ZMember, error)
+	ZRevRangeWithScores(key string, start, stop int) ([]ZMember, error)
+}
+
+// Scripter is implemented by backends running lua scripts atomically, e.g. redis client
+type Scripter interface {
+	// Eval runs the script, rcv receives the reply like radix.Cmd receivers
+	Eval(rcv interface{}, script *Script, keys []string, args ...string) error
+}
+
+// doCtx runs fn and returns early with the context error when ctx is done first.
+// fn keeps running in background until the client's own timeout, so its results
+// must not be read when doCtx returns a context error.
+func doCtx(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Topology Server topology usually for HA setup
@@ -35,10 +234,41 @@ type SentinelConfig struct {
 	Addrs []string
 }
 
+// TLSConfig TLS settings of redis connections
+type TLSConfig struct {
+	Enabled bool
+	//PEM file of CA verifying the server, system roots when empty
+	CAFile string
+	//PEM files of client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	//ServerName verified in the server certificate, host of the address when empty
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
 // Config set config for cache
 type Config struct {
-	Timeout  time.Duration
+	//URL sets topology, servers, credentials, DB and TLS at once, see ParseURL.
+	//Fields set by the URL override the fields below.
+	URL string
+
+	//Timeout of dial, read and write, overridden by the specific timeouts
+	Timeout      time.Duration
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	//Username redis 6 ACL user authenticated with AuthPass, default user when empty
+	Username string
 	AuthPass string
+	//DB database index, not supported by cluster
+	DB  int
+	TLS TLSConfig
+
+	//Metrics recording operations of the client, default is DefaultMetrics
+	Metrics Metrics
+
 	Topology Topology
 	Sentinel SentinelConfig
 
@@ -56,6 +286,16 @@ type Mock struct {
 	StubSet    func() error
 	StubAdd    func() error
 	StubDelete func() error
+
+	StubGetMulti    func() (map[string][]byte, error)
+	StubSetMulti    func() error
+	StubDeleteMulti func() error
+
+	StubIncr   func() (int64, error)
+	StubDecr   func() (int64, error)
+	StubTTL    func() (time.Duration, error)
+	StubTouch  func() error
+	StubExists func() (bool, error)
 }
 
 // SetLogger mocker
@@ -72,3 +312,33 @@ func (m *Mock) Delete(key string) error { return m.StubDelete() }
 
 // Get mocker
 func (m *Mock) Get(key string) ([]byte, error) { return m.StubGet() }
+
+// GetMulti mocker
+func (m *Mock) GetMulti(keys []string) (map[string][]byte, error) { return m.StubGetMulti() }
+
+// SetMulti mocker
+func (m *Mock) SetMulti(items map[string][]byte, expiration time.Duration) error {
+	return m.StubSetMulti()
+}
+
+// DeleteMulti mocker
+func (m *Mock) DeleteMulti(keys []string) error { return m.StubDeleteMulti() }
+
+// Incr mocker
+func (m *Mock) Incr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return m.StubIncr()
+}
+
+// Decr mocker
+func (m *Mock) Decr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return m.StubDecr()
+}
+
+// TTL mocker
+func (m *Mock) TTL(key string) (time.Duration, error) { return m.StubTTL() }
+
+// Touch mocker
+func (m *Mock) Touch(key string, expiration time.Duration) error { return m.StubTouch() }
+
+// Exists mocker
+func (m *Mock) Exists(key string) (bool, error) { return m.StubExists() }
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoCtx(t *testing.T) {
+	err := doCtx(context.Background(), func() error { return errors.New("failed") })
+	assert.EqualError(t, err, "failed")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = doCtx(ctx, func() error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	called := false
+	err = doCtx(ctx, func() error {
+		called = true
+		return nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.False(t, called)
+}
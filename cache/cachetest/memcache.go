@@ -0,0 +1,12 @@
+package cachetest
+
+import "github.com/armiariyan/bepkg/cache/internal/memcachestub"
+
+// MemcacheServer in-process memcache stand-in speaking the text protocol over TCP,
+// enough for cache.NewMemcache without a real server.
+type MemcacheServer = memcachestub.Server
+
+// NewMemcacheServer starts the stand-in on a random local port
+func NewMemcacheServer() (*MemcacheServer, error) {
+	return memcachestub.NewServer()
+}
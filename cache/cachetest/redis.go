@@ -0,0 +1,13 @@
+package cachetest
+
+import "github.com/armiariyan/bepkg/cache/internal/redisstub"
+
+// RedisServer in-process redis stand-in speaking RESP over TCP, enough for cache.NewRedis
+// key value operations without a real server. Lua is not interpreted, EVAL and EVALSHA
+// emulate only the scripts of the cache redis client, recognized by their SHA1.
+type RedisServer = redisstub.Server
+
+// NewRedisServer starts the stand-in on a random local port
+func NewRedisServer() (*RedisServer, error) {
+	return redisstub.NewServer()
+}
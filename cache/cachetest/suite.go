@@ -0,0 +1,230 @@
+// Package cachetest verifies cache.Keyval implementations behave identically.
+package cachetest
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/armiariyan/bepkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// Factory returns the Keyval under test, called once per sub test
+type Factory func(t *testing.T) cache.Keyval
+
+// expiryWait upper bound for an expired item to disappear, memcache rounds to seconds
+const expiryWait = 3 * time.Second
+
+// RunKeyvalSuite runs the Keyval contract against the caches of factory.
+// Keys are prefixed uniquely per run so real shared servers can be used.
+func RunKeyvalSuite(t *testing.T, factory Factory) {
+	prefix := "cachetest:" + strconv.FormatInt(time.Now().UnixNano(), 36) + ":"
+	key := func(name string) string {
+		return prefix + name
+	}
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, kv cache.Keyval, key func(string) string)
+	}{
+		{"Miss", testMiss},
+		{"AddNoOverwrite", testAddNoOverwrite},
+		{"SetOverwrite", testSetOverwrite},
+		{"DeleteMissing", testDeleteMissing},
+		{"Expiry", testExpiry},
+		{"BinaryValues", testBinaryValues},
+		{"Multi", testMulti},
+		{"Counters", testCounters},
+		{"Concurrent", testConcurrent},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, factory(t), func(name string) string {
+				return key(tt.name + ":" + name)
+			})
+		})
+	}
+}
+
+func testMiss(t *testing.T, kv cache.Keyval, key func(string) string) {
+	b, err := kv.Get(key("missing"))
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	items, err := kv.GetMulti([]string{key("missing"), key("missing2")})
+	assert.Nil(t, err)
+	assert.Empty(t, items)
+
+	ok, err := kv.Exists(key("missing"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ttl, err := kv.TTL(key("missing"))
+	if err != cache.ErrNotSupported {
+		assert.Nil(t, err)
+		assert.Equal(t, time.Duration(0), ttl)
+	}
+}
+
+func testAddNoOverwrite(t *testing.T, kv cache.Keyval, key func(string) string) {
+	assert.Nil(t, kv.Add(key("k"), []byte("first"), time.Minute))
+	assert.Nil(t, kv.Add(key("k"), []byte("second"), time.Minute))
+
+	b, err := kv.Get(key("k"))
+	assert.Nil(t, err)
+	assert.Equal(t, "first", string(b))
+}
+
+func testSetOverwrite(t *testing.T, kv cache.Keyval, key func(string) string) {
+	assert.Nil(t, kv.Set(key("k"), []byte("first"), time.Minute))
+	assert.Nil(t, kv.Set(key("k"), []byte("second"), time.Minute))
+
+	b, err := kv.Get(key("k"))
+	assert.Nil(t, err)
+	assert.Equal(t, "second", string(b))
+
+	ok, err := kv.Exists(key("k"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func testDeleteMissing(t *testing.T, kv cache.Keyval, key func(string) string) {
+	assert.Nil(t, kv.Delete(key("missing")))
+	assert.Nil(t, kv.DeleteMulti([]string{key("missing"), key("missing2")}))
+
+	assert.Nil(t, kv.Set(key("k"), []byte("val"), time.Minute))
+	assert.Nil(t, kv.Delete(key("k")))
+	assert.Nil(t, kv.Delete(key("k")))
+
+	b, err := kv.Get(key("k"))
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+}
+
+// eventuallyMissing waits until the key is gone
+func eventuallyMissing(t *testing.T, kv cache.Keyval, key string) {
+	deadline := time.Now().Add(expiryWait)
+	for time.Now().Before(deadline) {
+		b, err := kv.Get(key)
+		assert.Nil(t, err)
+		if b == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Errorf("%s did not expire in %s", key, expiryWait)
+}
+
+func testExpiry(t *testing.T, kv cache.Keyval, key func(string) string) {
+	assert.Nil(t, kv.Set(key("set"), []byte("val"), time.Second))
+	assert.Nil(t, kv.Add(key("add"), []byte("val"), time.Second))
+	assert.Nil(t, kv.Set(key("persistent"), []byte("val"), 0))
+
+	b, _ := kv.Get(key("set"))
+	assert.Equal(t, "val", string(b))
+	ttl, err := kv.TTL(key("set"))
+	if err != cache.ErrNotSupported {
+		assert.Nil(t, err)
+		assert.True(t, ttl > 0 && ttl <= time.Second, "ttl %s", ttl)
+
+		ttl, _ = kv.TTL(key("persistent"))
+		assert.Equal(t, cache.NoExpiration, ttl)
+	}
+
+	eventuallyMissing(t, kv, key("set"))
+	eventuallyMissing(t, kv, key("add"))
+
+	b, _ = kv.Get(key("persistent"))
+	assert.Equal(t, "val", string(b))
+	kv.Delete(key("persistent"))
+}
+
+func testBinaryValues(t *testing.T, kv cache.Keyval, key func(string) string) {
+	vals := map[string][]byte{
+		"nul":   {0, 1, 0, 2},
+		"crlf":  []byte("line\r\nEND\r\n"),
+		"high":  {0xff, 0xfe, 0x80},
+		"empty": {},
+		"large": bytes.Repeat([]byte{0, 0xff, 'a'}, 64<<10/3),
+	}
+	for name, val := range vals {
+		assert.Nil(t, kv.Set(key(name), val, time.Minute))
+
+		b, err := kv.Get(key(name))
+		assert.Nil(t, err)
+		assert.Equal(t, len(val), len(b), name)
+		assert.True(t, bytes.Equal(val, b), name)
+	}
+}
+
+func testMulti(t *testing.T, kv cache.Keyval, key func(string) string) {
+	assert.Nil(t, kv.SetMulti(map[string][]byte{
+		key("a"): []byte("1"),
+		key("b"): []byte("2"),
+	}, time.Minute))
+
+	items, err := kv.GetMulti([]string{key("a"), key("b"), key("missing")})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{key("a"): []byte("1"), key("b"): []byte("2")}, items)
+
+	assert.Nil(t, kv.DeleteMulti([]string{key("a"), key("missing")}))
+	items, err = kv.GetMulti([]string{key("a"), key("b")})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{key("b"): []byte("2")}, items)
+}
+
+func testCounters(t *testing.T, kv cache.Keyval, key func(string) string) {
+	n, err := kv.Incr(key("n"), 5, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), n)
+
+	n, err = kv.Incr(key("n"), 2, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), n)
+
+	n, err = kv.Decr(key("n"), 3, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), n)
+
+	b, err := kv.Get(key("n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "4", string(bytes.TrimSpace(b)))
+}
+
+func testConcurrent(t *testing.T, kv cache.Keyval, key func(string) string) {
+	const workers, rounds = 8, 25
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			own := key(fmt.Sprintf("worker-%d", w))
+			for i := 0; i < rounds; i++ {
+				if _, err := kv.Incr(key("counter"), 1, time.Minute); err != nil {
+					t.Error(err)
+					return
+				}
+				val := []byte(strconv.Itoa(i))
+				if err := kv.Set(own, val, time.Minute); err != nil {
+					t.Error(err)
+					return
+				}
+				if b, err := kv.Get(own); err != nil || !bytes.Equal(val, b) {
+					t.Errorf("%s got %q %v, want %q", own, b, err, val)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	n, err := kv.Incr(key("counter"), 0, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(workers*rounds), n)
+}
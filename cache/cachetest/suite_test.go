@@ -0,0 +1,78 @@
+package cachetest
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/armiariyan/bepkg/cache"
+)
+
+func TestMemory(t *testing.T) {
+	RunKeyvalSuite(t, func(t *testing.T) cache.Keyval {
+		return cache.NewMemory(cache.MemoryConfig{SweepInterval: time.Second})
+	})
+}
+
+func TestRedisServer(t *testing.T) {
+	s, err := NewRedisServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	RunKeyvalSuite(t, func(t *testing.T) cache.Keyval {
+		kv, err := cache.NewRedis(cache.Config{Servers: []string{s.Addr()}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { kv.Close() })
+		return kv
+	})
+}
+
+func TestMemcacheServer(t *testing.T) {
+	s, err := NewMemcacheServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	RunKeyvalSuite(t, func(t *testing.T) cache.Keyval {
+		return cache.NewMemcache([]string{s.Addr()})
+	})
+}
+
+func TestTiered(t *testing.T) {
+	RunKeyvalSuite(t, func(t *testing.T) cache.Keyval {
+		kv, err := cache.NewTiered(cache.NewMemory(cache.MemoryConfig{}), cache.NewMemory(cache.MemoryConfig{}), cache.TieredOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return kv
+	})
+}
+
+// TestRealServers runs the suite against servers of CACHETEST_REDIS and CACHETEST_MEMCACHE,
+// e.g. CACHETEST_REDIS=redis://127.0.0.1:6379/15
+func TestRealServers(t *testing.T) {
+	if url := os.Getenv("CACHETEST_REDIS"); url != "" {
+		t.Run("Redis", func(t *testing.T) {
+			RunKeyvalSuite(t, func(t *testing.T) cache.Keyval {
+				kv, err := cache.NewRedis(cache.Config{URL: url})
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { kv.Close() })
+				return kv
+			})
+		})
+	}
+	if addr := os.Getenv("CACHETEST_MEMCACHE"); addr != "" {
+		t.Run("Memcache", func(t *testing.T) {
+			RunKeyvalSuite(t, func(t *testing.T) cache.Keyval {
+				return cache.NewMemcache([]string{addr})
+			})
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/armiariyan/bepkg/logger"
+)
+
+// instrumented Keyval decorator recording metrics of every operation
+type instrumented struct {
+	kv      Keyval
+	backend string
+	metrics Metrics
+}
+
+// Instrument records metrics of kv operations to DefaultMetrics.
+// Redis and memcache clients and instrumented caches already record their metrics and
+// are returned as is, set Config.Metrics or use InstrumentWith to record them elsewhere.
+// The result exposes Keyval methods and Close only.
+func Instrument(kv Keyval) Keyval {
+	switch kv.(type) {
+	case *rcache, *mcache, *instrumented:
+		return kv
+	}
+	return InstrumentWith(kv, backendName(kv), DefaultMetrics)
+}
+
+// InstrumentWith records metrics of kv operations to m under the backend name
+func InstrumentWith(kv Keyval, backend string, m Metrics) Keyval {
+	return &instrumented{kv: kv, backend: backend, metrics: m}
+}
+
+func backendName(kv Keyval) string {
+	switch kv.(type) {
+	case *rcache:
+		return "redis"
+	case *mcache:
+		return "memcache"
+	case *lcache:
+		return "memory"
+	case *tcache:
+		return "tiered"
+	}
+	return fmt.Sprintf("%T", kv)
+}
+
+func (i *instrumented) observe(operation string, start time.Time, err *error) {
+	observe(i.metrics, i.backend, operation, start, *err)
+}
+
+func (i *instrumented) SetLogger(l logger.Logger) {
+	i.kv.SetLogger(l)
+}
+
+func (i *instrumented) Add(key string, val []byte, expiration time.Duration) (err error) {
+	defer i.observe("add", time.Now(), &err)
+	return i.kv.Add(key, val, expiration)
+}
+
+func (i *instrumented) Set(key string, val []byte, expiration time.Duration) (err error) {
+	defer i.observe("set", time.Now(), &err)
+	return i.kv.Set(key, val, expiration)
+}
+
+func (i *instrumented) Delete(key string) (err error) {
+	defer i.observe("delete", time.Now(), &err)
+	return i.kv.Delete(key)
+}
+
+func (i *instrumented) Get(key string) (val []byte, err error) {
+	defer func(start time.Time) {
+		observeGet(i.metrics, i.backend, "get", start, val != nil, err)
+	}(time.Now())
+	return i.kv.Get(key)
+}
+
+func (i *instrumented) GetMulti(keys []string) (items map[string][]byte, err error) {
+	defer func(start time.Time) {
+		observeGetMulti(i.metrics, i.backend, "get_multi", start, len(keys), len(items), err)
+	}(time.Now())
+	return i.kv.GetMulti(keys)
+}
+
+func (i *instrumented) SetMulti(items map[string][]byte, expiration time.Duration) (err error) {
+	defer i.observe("set_multi", time.Now(), &err)
+	return i.kv.SetMulti(items, expiration)
+}
+
+func (i *instrumented) DeleteMulti(keys []string) (err error) {
+	defer i.observe("delete_multi", time.Now(), &err)
+	return i.kv.DeleteMulti(keys)
+}
+
+func (i *instrumented) Incr(key string, delta int64, expiration time.Duration) (n int64, err error) {
+	defer i.observe("incr", time.Now(), &err)
+	return i.kv.Incr(key, delta, expiration)
+}
+
+func (i *instrumented) Decr(key string, delta int64, expiration time.Duration) (n int64, err error) {
+	defer i.observe("decr", time.Now(), &err)
+	return i.kv.Decr(key, delta, expiration)
+}
+
+func (i *instrumented) TTL(key string) (ttl time.Duration, err error) {
+	defer i.observe("ttl", time.Now(), &err)
+	return i.kv.TTL(key)
+}
+
+func (i *instrumented) Touch(key string, expiration time.Duration) (err error) {
+	defer i.observe("touch", time.Now(), &err)
+	return i.kv.Touch(key, expiration)
+}
+
+func (i *instrumented) Exists(key string) (ok bool, err error) {
+	defer i.observe("exists", time.Now(), &err)
+	return i.kv.Exists(key)
+}
+
+// Close closes the wrapped cache if it is closable
+func (i *instrumented) Close() error {
+	if c, ok := i.kv.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (i *instrumented) unwrap() Keyval {
+	return i.kv
+}
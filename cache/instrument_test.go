@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	m := NewPrometheusMetrics(nil)
+	x := InstrumentWith(NewMemory(MemoryConfig{}), "memory", m)
+	defer x.(interface{ Close() error }).Close()
+
+	assert.Nil(t, x.Set("test", []byte("ini isi test"), time.Hour))
+	x.Get("test")
+	x.Get("missing")
+	x.Incr("counter", 1, time.Hour)
+	x.GetMulti([]string{"test", "missing"})
+
+	assert.Equal(t, uint64(1), m.Count("memory", "set", ResultOK))
+	assert.Equal(t, uint64(1), m.Count("memory", "get", ResultHit))
+	assert.Equal(t, uint64(1), m.Count("memory", "get", ResultMiss))
+	assert.Equal(t, uint64(1), m.Count("memory", "incr", ResultOK))
+	assert.Equal(t, uint64(1), m.Count("memory", "get_multi", ResultHit))
+	assert.Equal(t, uint64(1), m.Count("memory", "get_multi", ResultMiss))
+}
+
+func TestInstrumentBackendName(t *testing.T) {
+	assert.Equal(t, "memory", backendName(NewMemory(MemoryConfig{})))
+	assert.Equal(t, "*cache.Mock", backendName(&Mock{}))
+}
+
+func TestRedisMetrics(t *testing.T) {
+	x := newStubRedis(t, Standalone)
+	m := NewPrometheusMetrics(nil)
+	x.metrics = m
+
+	x.Set("test", []byte("ini isi test"), time.Minute)
+	x.Get("test")
+	x.Get("missing")
+
+	assert.Equal(t, uint64(1), m.Count("redis", "set", ResultOK))
+	assert.Equal(t, uint64(1), m.Count("redis", "get", ResultHit))
+	assert.Equal(t, uint64(1), m.Count("redis", "get", ResultMiss))
+
+	x.Decr("counter", 1, 0)
+	assert.Equal(t, uint64(1), m.Count("redis", "decr", ResultOK))
+	assert.Equal(t, uint64(0), m.Count("redis", "incr", ResultOK))
+
+	x.GetMulti([]string{"test", "missing", "other"})
+	assert.Equal(t, uint64(1), m.Count("redis", "get_multi", ResultHit))
+	assert.Equal(t, uint64(2), m.Count("redis", "get_multi", ResultMiss))
+}
+
+func TestMemcacheMetrics(t *testing.T) {
+	x := newStubMemcache(t)
+	m := NewPrometheusMetrics(nil)
+	x.metrics = m
+
+	x.SetMulti(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, time.Minute)
+	x.GetMulti([]string{"a", "b", "missing"})
+	x.DeleteMulti([]string{"a", "b"})
+	x.Incr("counter", 2, time.Minute)
+	x.Decr("counter", 1, time.Minute)
+
+	assert.Equal(t, uint64(1), m.Count("memcache", "set_multi", ResultOK))
+	assert.Equal(t, uint64(0), m.Count("memcache", "set", ResultOK))
+	assert.Equal(t, uint64(1), m.Count("memcache", "delete_multi", ResultOK))
+	assert.Equal(t, uint64(0), m.Count("memcache", "delete", ResultOK))
+	assert.Equal(t, uint64(2), m.Count("memcache", "get_multi", ResultHit))
+	assert.Equal(t, uint64(1), m.Count("memcache", "get_multi", ResultMiss))
+	assert.Equal(t, uint64(1), m.Count("memcache", "incr", ResultOK))
+	assert.Equal(t, uint64(1), m.Count("memcache", "decr", ResultOK))
+}
+
+func TestInstrumentRecordsOnce(t *testing.T) {
+	x := newStubRedis(t, Standalone)
+	assert.Equal(t, Keyval(x), Instrument(x))
+
+	mc := NewMemcacheWithMetrics([]string{"127.0.0.1:11211"}, NewPrometheusMetrics(nil))
+	assert.Equal(t, Keyval(mc), Instrument(mc))
+
+	memory := Instrument(NewMemory(MemoryConfig{}))
+	assert.Equal(t, memory, Instrument(memory))
+}
@@ -0,0 +1,269 @@
+// Package memcachestub in-process memcache stand-in for tests of the cache package and cachetest
+package memcachestub
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRelativeExpiration memcache treats expirations above 30 days as absolute unix time
+const maxRelativeExpiration = 30 * 24 * 3600
+
+type item struct {
+	val   []byte
+	flags uint32
+	cas   uint64
+	exp   time.Time
+}
+
+// Server in-process memcache stand-in speaking the text protocol over TCP, enough for
+// the commands of gomemcache: gets, set, add, replace, cas, delete, incr, decr, touch.
+// Keys are validated like memcache does.
+type Server struct {
+	l net.Listener
+
+	mu    sync.Mutex
+	items map[string]*item
+	cas   uint64
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// NewServer starts the stand-in on a random local port
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		l:     l,
+		items: map[string]*item{},
+		conns: map[net.Conn]struct{}{},
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Addr returns host:port the stand-in listens on
+func (s *Server) Addr() string {
+	return s.l.Addr().String()
+}
+
+// Close stops the stand-in and closes client connections
+func (s *Server) Close() error {
+	err := s.l.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// Get returns the stored value of the key
+func (s *Server) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.live(key)
+	if !ok {
+		return nil, false
+	}
+	return it.val, true
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	br := bufio.NewReader(conn)
+	bw := bufio.NewWriter(conn)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		var data []byte
+		switch args[0] {
+		case "set", "add", "replace", "cas":
+			if len(args) < 5 {
+				bw.WriteString("ERROR\r\n")
+				break
+			}
+			size, err := strconv.Atoi(args[4])
+			if err != nil || size < 0 {
+				bw.WriteString("CLIENT_ERROR bad data chunk\r\n")
+				break
+			}
+			data = make([]byte, size+2)
+			if _, err = io.ReadFull(br, data); err != nil {
+				return
+			}
+			data = data[:size]
+		}
+
+		s.handle(bw, args, data)
+		if err = bw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// legalKey reports whether memcache accepts the key
+func legalKey(key string) bool {
+	if len(key) == 0 || len(key) > 250 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// live returns the item unless it expired
+func (s *Server) live(key string) (*item, bool) {
+	it, ok := s.items[key]
+	if ok && !it.exp.IsZero() && !time.Now().Before(it.exp) {
+		delete(s.items, key)
+		return nil, false
+	}
+	return it, ok
+}
+
+func expiration(arg string) (time.Time, error) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	switch {
+	case err != nil:
+		return time.Time{}, err
+	case n == 0:
+		return time.Time{}, nil
+	case n > maxRelativeExpiration:
+		return time.Unix(n, 0), nil
+	}
+	return time.Now().Add(time.Duration(n) * time.Second), nil
+}
+
+func (s *Server) handle(bw *bufio.Writer, args []string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cmd := args[0]
+	if cmd != "gets" && cmd != "get" && (len(args) < 2 || !legalKey(args[1])) {
+		bw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return
+	}
+
+	switch cmd {
+	case "get", "gets":
+		for _, key := range args[1:] {
+			it, ok := s.live(key)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(bw, "VALUE %s %d %d %d\r\n", key, it.flags, len(it.val), it.cas)
+			bw.Write(it.val)
+			bw.WriteString("\r\n")
+		}
+		bw.WriteString("END\r\n")
+	case "set", "add", "replace", "cas":
+		flags, ferr := strconv.ParseUint(args[2], 10, 32)
+		exp, eerr := expiration(args[3])
+		if ferr != nil || eerr != nil {
+			bw.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return
+		}
+		cur, exists := s.live(args[1])
+		switch {
+		case cmd == "add" && exists, cmd == "replace" && !exists:
+			bw.WriteString("NOT_STORED\r\n")
+			return
+		case cmd == "cas" && !exists:
+			bw.WriteString("NOT_FOUND\r\n")
+			return
+		case cmd == "cas" && (len(args) < 6 || args[5] != strconv.FormatUint(cur.cas, 10)):
+			bw.WriteString("EXISTS\r\n")
+			return
+		}
+		s.cas++
+		s.items[args[1]] = &item{val: data, flags: uint32(flags), cas: s.cas, exp: exp}
+		bw.WriteString("STORED\r\n")
+	case "delete":
+		if _, ok := s.live(args[1]); !ok {
+			bw.WriteString("NOT_FOUND\r\n")
+			return
+		}
+		delete(s.items, args[1])
+		bw.WriteString("DELETED\r\n")
+	case "incr", "decr":
+		it, ok := s.live(args[1])
+		if !ok {
+			bw.WriteString("NOT_FOUND\r\n")
+			return
+		}
+		delta, err := strconv.ParseUint(args[2], 10, 64)
+		n, nerr := strconv.ParseUint(string(it.val), 10, 64)
+		if err != nil || nerr != nil {
+			bw.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return
+		}
+		if cmd == "incr" {
+			n += delta
+		} else if delta > n {
+			n = 0
+		} else {
+			n -= delta
+		}
+		s.cas++
+		it.val, it.cas = []byte(strconv.FormatUint(n, 10)), s.cas
+		fmt.Fprintf(bw, "%d\r\n", n)
+	case "touch":
+		it, ok := s.live(args[1])
+		exp, err := expiration(args[2])
+		switch {
+		case err != nil:
+			bw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		case !ok:
+			bw.WriteString("NOT_FOUND\r\n")
+		default:
+			it.exp = exp
+			bw.WriteString("TOUCHED\r\n")
+		}
+	default:
+		bw.WriteString("ERROR\r\n")
+	}
+}
@@ -0,0 +1,404 @@
+// Package redisstub in-process redis stand-in for tests of the cache package and cachetest
+package redisstub
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server in-process redis stand-in speaking RESP over TCP, enough for cache.NewRedis
+// key value operations without a real server. Lua is not interpreted, EVAL and EVALSHA
+// emulate only the scripts of the cache redis client.
+type Server struct {
+	l net.Listener
+
+	mu      sync.Mutex
+	data    map[string]string
+	expires map[string]time.Time
+	conns   map[net.Conn]struct{}
+	wg      sync.WaitGroup
+}
+
+// NewServer starts the stand-in on a random local port
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		l:       l,
+		data:    map[string]string{},
+		expires: map[string]time.Time{},
+		conns:   map[net.Conn]struct{}{},
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Addr returns host:port the stand-in listens on
+func (s *Server) Addr() string {
+	return s.l.Addr().String()
+}
+
+// Close stops the stand-in and closes client connections
+func (s *Server) Close() error {
+	err := s.l.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// Get returns the stored value of the key
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.live(key)
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	br := bufio.NewReader(conn)
+	bw := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(br)
+		if err != nil {
+			return
+		}
+		writeReply(bw, s.handle(args))
+		if err = bw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads RESP array of bulk strings
+func readCommand(br *bufio.Reader) ([]string, error) {
+	line, err := readLine(br)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, errors.New("redisstub: inline commands are not supported")
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if line, err = readLine(br); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// redisError error reply
+type redisError string
+
+func writeReply(bw *bufio.Writer, reply interface{}) {
+	switch r := reply.(type) {
+	case nil:
+		bw.WriteString("$-1\r\n")
+	case redisError:
+		fmt.Fprintf(bw, "-%s\r\n", r)
+	case int64:
+		fmt.Fprintf(bw, ":%d\r\n", r)
+	case int:
+		fmt.Fprintf(bw, ":%d\r\n", r)
+	case string:
+		fmt.Fprintf(bw, "$%d\r\n%s\r\n", len(r), r)
+	case []interface{}:
+		fmt.Fprintf(bw, "*%d\r\n", len(r))
+		for _, item := range r {
+			writeReply(bw, item)
+		}
+	}
+}
+
+var okReply = "OK"
+
+// live returns the value unless the key expired
+func (s *Server) live(key string) (string, bool) {
+	val, ok := s.data[key]
+	if exp, has := s.expires[key]; ok && has && !time.Now().Before(exp) {
+		delete(s.data, key)
+		delete(s.expires, key)
+		return "", false
+	}
+	return val, ok
+}
+
+func (s *Server) expire(key string, ms int64) {
+	s.expires[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+}
+
+func (s *Server) incrBy(key, delta string) (interface{}, bool) {
+	val, _ := s.live(key)
+	if val == "" {
+		val = "0"
+	}
+	n, err := strconv.ParseInt(val, 10, 64)
+	d, derr := strconv.ParseInt(delta, 10, 64)
+	if err != nil || derr != nil {
+		return redisError("ERR value is not an integer or out of range"), false
+	}
+	s.data[key] = strconv.FormatInt(n+d, 10)
+	return n + d, true
+}
+
+func wrongArgs(cmd string) redisError {
+	return redisError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+}
+
+func (s *Server) handle(args []string) interface{} {
+	if len(args) == 0 {
+		return redisError("ERR empty command")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cmd := strings.ToUpper(args[0])
+	switch cmd {
+	case "PING":
+		return "PONG"
+	case "AUTH", "SELECT":
+		return okReply
+	case "GET":
+		if len(args) != 2 {
+			return wrongArgs(cmd)
+		}
+		if val, ok := s.live(args[1]); ok {
+			return val
+		}
+		return nil
+	case "MGET":
+		vals := make([]interface{}, 0, len(args)-1)
+		for _, key := range args[1:] {
+			if val, ok := s.live(key); ok {
+				vals = append(vals, val)
+			} else {
+				vals = append(vals, nil)
+			}
+		}
+		return vals
+	case "SET":
+		return s.set(args)
+	case "DEL", "EXISTS":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.live(key); ok {
+				n++
+			}
+			if cmd == "DEL" {
+				delete(s.data, key)
+				delete(s.expires, key)
+			}
+		}
+		return n
+	case "INCRBY":
+		if len(args) != 3 {
+			return wrongArgs(cmd)
+		}
+		reply, _ := s.incrBy(args[1], args[2])
+		return reply
+	case "PTTL":
+		if len(args) != 2 {
+			return wrongArgs(cmd)
+		}
+		if _, ok := s.live(args[1]); !ok {
+			return -2
+		}
+		exp, ok := s.expires[args[1]]
+		if !ok {
+			return -1
+		}
+		return int64(time.Until(exp) / time.Millisecond)
+	case "PEXPIRE":
+		if len(args) != 3 {
+			return wrongArgs(cmd)
+		}
+		if _, ok := s.live(args[1]); !ok {
+			return 0
+		}
+		ms, _ := strconv.ParseInt(args[2], 10, 64)
+		s.expire(args[1], ms)
+		return 1
+	case "PERSIST":
+		if _, ok := s.expires[args[1]]; !ok {
+			return 0
+		}
+		delete(s.expires, args[1])
+		return 1
+	case "EVAL", "EVALSHA":
+		return s.eval(cmd, args)
+	}
+	return redisError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+}
+
+func (s *Server) set(args []string) interface{} {
+	if len(args) < 3 {
+		return wrongArgs("SET")
+	}
+	key, val := args[1], args[2]
+	var ms int64
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX", "XX":
+			_, ok := s.live(key)
+			if ok == (opt == "NX") {
+				return nil
+			}
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				return redisError("ERR syntax error")
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return redisError("ERR invalid expire time in set")
+			}
+			ms = n
+			if opt == "EX" {
+				ms = n * 1000
+			}
+			i++
+		default:
+			return redisError("ERR syntax error")
+		}
+	}
+
+	s.data[key] = val
+	delete(s.expires, key)
+	if ms > 0 {
+		s.expire(key, ms)
+	}
+	return okReply
+}
+
+// Emulated scripts by SHA1 of their exact body, a changed script of the cache redis client
+// fails with NOSCRIPT until its emulation is updated
+var scripts = map[string]script{
+	//incrScript
+	"5986cb2ac9302fc9502198b54b001fabf39bb7cc": {2, func(s *Server, key string, argv []string) interface{} {
+		reply, ok := s.incrBy(key, argv[0])
+		if _, has := s.expires[key]; ok && !has {
+			if ms, _ := strconv.ParseInt(argv[1], 10, 64); ms > 0 {
+				s.expire(key, ms)
+			}
+		}
+		return reply
+	}},
+	//compareAndSwapScript
+	"694c947a579bd09c64ede3f2182f361963b367d6": {4, func(s *Server, key string, argv []string) interface{} {
+		cur, ok := s.live(key)
+		if (argv[0] == "1") != ok || (ok && cur != argv[1]) {
+			return 0
+		}
+		s.data[key] = argv[2]
+		delete(s.expires, key)
+		if ms, _ := strconv.ParseInt(argv[3], 10, 64); ms > 0 {
+			s.expire(key, ms)
+		}
+		return 1
+	}},
+	//compareAndExpireScript
+	"9f576ddd46c8290d842286a4bf8fe55af5296170": {2, func(s *Server, key string, argv []string) interface{} {
+		if cur, ok := s.live(key); !ok || cur != argv[0] {
+			return 0
+		}
+		ms, _ := strconv.ParseInt(argv[1], 10, 64)
+		s.expire(key, ms)
+		return 1
+	}},
+	//compareAndDeleteScript
+	"21a618e89bac83c2b4df1fcbb0c42dba362402f4": {1, func(s *Server, key string, argv []string) interface{} {
+		if cur, ok := s.live(key); !ok || cur != argv[0] {
+			return 0
+		}
+		delete(s.data, key)
+		delete(s.expires, key)
+		return 1
+	}},
+}
+
+// script emulation of a script taking one key and args arguments
+type script struct {
+	args int
+	run  func(s *Server, key string, argv []string) interface{}
+}
+
+// eval runs the emulation of the script given by body for EVAL or by SHA1 for EVALSHA
+func (s *Server) eval(cmd string, args []string) interface{} {
+	if len(args) < 4 || args[2] != "1" {
+		return wrongArgs(cmd)
+	}
+	sha, key, argv := args[1], args[3], args[4:]
+	if cmd == "EVAL" {
+		sum := sha1.Sum([]byte(args[1]))
+		sha = hex.EncodeToString(sum[:])
+	}
+
+	sc, ok := scripts[sha]
+	switch {
+	case !ok && cmd == "EVALSHA":
+		return redisError("NOSCRIPT No matching script. Please use EVAL.")
+	case !ok:
+		return redisError("ERR redisstub doesn't interpret lua scripts")
+	case len(argv) != sc.args:
+		return wrongArgs(cmd)
+	}
+	return sc.run(s, key, argv)
+}
@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/armiariyan/bepkg/logger"
+)
+
+// notFoundMarker is stored by negative caching under notFoundKey to remember a not found result
+var notFoundMarker = []byte("1")
+
+// loadKeyPrefix reserved prefix of the entries GetOrLoad keeps beside the item, printable
+// as memcache rejects control characters in keys
+const loadKeyPrefix = "_load:"
+
+// LoadOptions set options for GetOrLoadWithOptions
+type LoadOptions struct {
+	//Expiration of cached not found result, the loader signals not found by returning ErrNotFound.
+	//0 disables negative caching
+	NegativeTTL time.Duration
+
+	//Lock makes only one instance recompute the key, using Locker on backends supporting it.
+	//Backends without locking, e.g. memcache, load without the lock.
+	Lock bool
+	//Expiration of the lock, default is 10 seconds
+	LockTTL time.Duration
+	//Maximum time waiting for another instance to load the key before loading it anyway,
+	//default is LockTTL
+	LockWait time.Duration
+
+	//Stale copy is kept StaleTTL longer than the item and served while another instance
+	//holds the lock, 0 disables stale copies
+	StaleTTL time.Duration
+}
+
+type loadKey struct {
+	kv  Keyval
+	key string
+}
+
+type loadCall struct {
+	wg  sync.WaitGroup
+	val []byte
+	err error
+	//panic of the loader, raised again in every caller
+	panic interface{}
+}
+
+// loadGroup deduplicates concurrent loads of the same key within the process
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[loadKey]*loadCall
+}
+
+func (g *loadGroup) do(k loadKey, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[loadKey]*loadCall)
+	}
+	if c, ok := g.calls[k]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		if c.panic != nil {
+			panic(c.panic)
+		}
+		return c.val, c.err
+	}
+	c := new(loadCall)
+	c.wg.Add(1)
+	g.calls[k] = c
+	g.mu.Unlock()
+
+	//a panicking loader must not leave the key blocked
+	defer func() {
+		if r := recover(); r != nil {
+			c.panic = r
+		}
+		g.mu.Lock()
+		delete(g.calls, k)
+		g.mu.Unlock()
+		c.wg.Done()
+
+		if c.panic != nil {
+			panic(c.panic)
+		}
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
+
+var loads loadGroup
+
+// GetOrLoad gets the item with the provided key, on miss the value returned by loader
+// is stored with the given expiration. Concurrent loads of the same key are deduplicated.
+func GetOrLoad(kv Keyval, key string, expiration time.Duration, loader func() ([]byte, error)) ([]byte, error) {
+	return GetOrLoadWithOptions(kv, key, expiration, loader, LoadOptions{})
+}
+
+// GetOrLoadWithOptions is GetOrLoad with negative caching and cross instance locking.
+// Return ErrNotFound if the loader or the negative cache reports the item does not exist,
+// joined with the cache error when the not found result can't be stored, check it with errors.Is.
+func GetOrLoadWithOptions(kv Keyval, key string, expiration time.Duration, loader func() ([]byte, error), opts LoadOptions) ([]byte, error) {
+	if val, ok, err := getCached(kv, key, opts); ok {
+		return val, err
+	}
+
+	return loads.do(loadKey{kv: kv, key: key}, func() ([]byte, error) {
+		// another caller may have finished loading while we were waiting for the group
+		if val, ok, err := getCached(kv, key, opts); ok {
+			return val, err
+		}
+
+		if opts.Lock {
+			//backends without locking load without the lock
+			if locker, err := NewLocker(kv, LockerOptions{}); err == nil {
+				val, done, unlock, err := waitLoad(kv, locker, key, opts)
+				if done {
+					return val, err
+				}
+				defer unlock()
+			}
+		}
+
+		val, err := loader()
+		if err == ErrNotFound && opts.NegativeTTL > 0 {
+			if serr := kv.Set(notFoundKey(key), notFoundMarker, opts.NegativeTTL); serr != nil {
+				logLoadError(kv, notFoundKey(key), serr)
+				return nil, errors.Join(ErrNotFound, serr)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		//the loaded value is served even when it can't be cached
+		if serr := kv.Set(key, val, expiration); serr != nil {
+			logLoadError(kv, key, serr)
+		}
+		if opts.StaleTTL > 0 {
+			if serr := kv.Set(staleKey(key), val, expiration+opts.StaleTTL); serr != nil {
+				logLoadError(kv, staleKey(key), serr)
+			}
+		}
+
+		return val, nil
+	})
+}
+
+// getLoaded treats cache errors as miss so the loader still serves the request
+func getLoaded(kv Keyval, key string) ([]byte, bool) {
+	val, err := kv.Get(key)
+	return val, err == nil && val != nil
+}
+
+// getCached returns the loaded item or ErrNotFound when a not found result is cached,
+// ok reports either was found
+func getCached(kv Keyval, key string, opts LoadOptions) ([]byte, bool, error) {
+	if val, ok := getLoaded(kv, key); ok {
+		return val, true, nil
+	}
+	if opts.NegativeTTL > 0 {
+		if _, ok := getLoaded(kv, notFoundKey(key)); ok {
+			return nil, true, ErrNotFound
+		}
+	}
+	return nil, false, nil
+}
+
+// notFoundKey stores the negative cache entry apart from the item
+func notFoundKey(key string) string {
+	return loadKeyPrefix + "nf:" + key
+}
+
+func staleKey(key string) string {
+	return loadKeyPrefix + "stale:" + key
+}
+
+func lockKey(key string) string {
+	return loadKeyPrefix + "lock:" + key
+}
+
+// loggerHolder is implemented by caches logging their errors
+type loggerHolder interface {
+	cacheLogger() logger.Logger
+}
+
+// kvLogger returns the logger of kv, looking through decorators
+func kvLogger(kv Keyval) logger.Logger {
+	for {
+		if h, ok := kv.(loggerHolder); ok {
+			return h.cacheLogger()
+		}
+		u, ok := kv.(unwrapper)
+		if !ok {
+			return nil
+		}
+		kv = u.unwrap()
+	}
+}
+
+// logLoadError logs entries GetOrLoad failed to write through the logger of kv
+func logLoadError(kv Keyval, key string, err error) {
+	if l := kvLogger(kv); l != nil {
+		l.Error("cache",
+			logger.ToField("caller", logger.Caller(2)),
+			logger.ToField("message", fmt.Sprintf("load %s %s", key, err.Error())),
+		)
+	}
+}
+
+// waitLoad takes the load lock of key. When another instance holds it, the stale copy
+// is served or the key is polled until loaded. done reports val and err should be returned as is,
+// otherwise the caller loads the key and calls unlock afterwards.
+func waitLoad(kv Keyval, locker *Locker, key string, opts LoadOptions) (val []byte, done bool, unlock func(), err error) {
+	lockTTL := opts.LockTTL
+	if lockTTL <= 0 {
+		lockTTL = 10 * time.Second
+	}
+	wait := opts.LockWait
+	if wait <= 0 {
+		wait = lockTTL
+	}
+
+	unlock = func() {}
+	lock, lerr := locker.TryLock(lockKey(key), lockTTL)
+	if lerr == nil {
+		unlock = func() { lock.Unlock() }
+		return
+	}
+	if lerr != ErrLockNotObtained {
+		return
+	}
+
+	if opts.StaleTTL > 0 {
+		if val, ok := getLoaded(kv, staleKey(key)); ok {
+			return val, true, unlock, nil
+		}
+	}
+
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		if val, ok, err := getCached(kv, key, opts); ok {
+			return val, true, unlock, err
+		}
+	}
+
+	return
+}
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrLoadDeduplicate(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+	var calls int32
+
+	loader := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return []byte("loaded"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := GetOrLoad(x, "test", time.Hour, loader)
+			assert.Nil(t, err)
+			assert.Equal(t, "loaded", string(b))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	b, _ := x.Get("test")
+	assert.Equal(t, "loaded", string(b))
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+
+	_, err := GetOrLoad(x, "test", time.Hour, func() ([]byte, error) {
+		return nil, errors.New("db down")
+	})
+	assert.EqualError(t, err, "db down")
+
+	b, _ := x.Get("test")
+	assert.Nil(t, b)
+}
+
+func TestGetOrLoadNegative(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+	calls := 0
+	loader := func() ([]byte, error) {
+		calls++
+		return nil, ErrNotFound
+	}
+	opts := LoadOptions{NegativeTTL: time.Hour}
+
+	_, err := GetOrLoadWithOptions(x, "test", time.Hour, loader, opts)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = GetOrLoadWithOptions(x, "test", time.Hour, loader, opts)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, 1, calls)
+
+	//the item key itself stays empty
+	b, err := x.Get("test")
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+}
+
+func TestGetOrLoadNegativeSetError(t *testing.T) {
+	m := &Mock{
+		StubGet: func() ([]byte, error) { return nil, nil },
+		StubSet: func() error { return errors.New("cache down") },
+	}
+
+	_, err := GetOrLoadWithOptions(m, "test", time.Hour, func() ([]byte, error) {
+		return nil, ErrNotFound
+	}, LoadOptions{NegativeTTL: time.Hour})
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Contains(t, err.Error(), "cache down")
+}
+
+func TestGetOrLoadNegativeMemcache(t *testing.T) {
+	x := newStubMemcache(t)
+	calls := 0
+	loader := func() ([]byte, error) {
+		calls++
+		return nil, ErrNotFound
+	}
+	opts := LoadOptions{NegativeTTL: time.Hour, StaleTTL: time.Hour, Lock: true}
+
+	_, err := GetOrLoadWithOptions(x, "test", time.Hour, loader, opts)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = GetOrLoadWithOptions(x, "test", time.Hour, loader, opts)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, 1, calls)
+
+	b, err := GetOrLoadWithOptions(x, "found", time.Hour, func() ([]byte, error) { return []byte("1"), nil }, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", string(b))
+	b, _ = x.Get(staleKey("found"))
+	assert.Equal(t, "1", string(b))
+}
+
+func TestLoadDecorated(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+	l := newStdoutLogger(t)
+	x.SetLogger(l)
+
+	decorated := Namespace(Instrument(x), "app")
+	assert.Equal(t, l, kvLogger(decorated))
+	_, err := NewLocker(decorated, LockerOptions{})
+	assert.Nil(t, err)
+
+	assert.Nil(t, kvLogger(&Mock{}))
+	_, err = NewLocker(newStubMemcache(t), LockerOptions{})
+	assert.Equal(t, ErrLockUnsupported, err)
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	//a concurrent caller waiting for the panicking load gets the panic too
+	waiter := make(chan interface{})
+	go func() {
+		<-started
+		defer func() { waiter <- recover() }()
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+		}()
+		GetOrLoad(x, "p", time.Hour, func() ([]byte, error) { return []byte("unused"), nil })
+	}()
+
+	assert.Panics(t, func() {
+		GetOrLoad(x, "p", time.Hour, func() ([]byte, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	})
+	assert.Equal(t, "boom", <-waiter)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b, err := GetOrLoad(x, "p", time.Hour, func() ([]byte, error) { return []byte("loaded"), nil })
+		assert.Nil(t, err)
+		assert.Equal(t, "loaded", string(b))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetOrLoad blocked after a panicking loader")
+	}
+}
+
+func TestGetOrLoadLock(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+	opts := LoadOptions{Lock: true, LockWait: time.Second, StaleTTL: time.Hour}
+	loader := func() ([]byte, error) { return []byte("fresh"), nil }
+
+	// another instance is loading and a stale copy exists
+	x.Set(lockKey("test"), []byte("1"), time.Minute)
+	x.Set(staleKey("test"), []byte("stale"), time.Hour)
+
+	b, err := GetOrLoadWithOptions(x, "test", time.Hour, loader, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "stale", string(b))
+
+	// without stale copy the caller waits for the other instance
+	x.Delete(staleKey("test"))
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		x.Set("test", []byte("other"), time.Hour)
+	}()
+
+	b, err = GetOrLoadWithOptions(x, "test", time.Hour, loader, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "other", string(b))
+
+	// lock is released after loading
+	x.Delete(lockKey("test"))
+	x.Delete("test")
+
+	b, err = GetOrLoadWithOptions(x, "test", time.Hour, loader, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "fresh", string(b))
+
+	b, _ = x.Get(lockKey("test"))
+	assert.Nil(t, b)
+	b, _ = x.Get(staleKey("test"))
+	assert.Equal(t, "fresh", string(b))
+}
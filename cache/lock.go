@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLockNotObtained returned by TryLock when the lock is held by another owner
+	ErrLockNotObtained = errors.New("cache: lock not obtained")
+	// ErrLockNotHeld returned by Unlock and Extend when the lock expired or was taken over
+	ErrLockNotHeld = errors.New("cache: lock not held")
+	// ErrLockUnsupported returned by NewLocker when the backend can't provide locking
+	ErrLockUnsupported = errors.New("cache: backend does not support locking")
+	// ErrLockTTL returned by Lock, TryLock and Extend for ttl shorter than one millisecond,
+	// locks always expire and their ttl is sent in milliseconds
+	ErrLockTTL = errors.New("cache: lock ttl must be at least one millisecond")
+)
+
+// lockBackend is implemented by backends able to provide owner checked locks
+type lockBackend interface {
+	setNX(key string, val []byte, expiration time.Duration) (bool, error)
+	compareAndDelete(key string, val []byte) (bool, error)
+	compareAndExpire(key string, val []byte, expiration time.Duration) (bool, error)
+}
+
+// unwrapper is implemented by decorators keeping keys of the wrapped Keyval unchanged
+type unwrapper interface {
+	unwrap() Keyval
+}
+
+// findLockBackend returns the lock backend of kv, looking through decorators keeping keys
+func findLockBackend(kv Keyval) (lockBackend, bool) {
+	for {
+		if lb, ok := kv.(lockBackend); ok {
+			return lb, true
+		}
+		u, ok := kv.(unwrapper)
+		if !ok {
+			return nil, false
+		}
+		kv = u.unwrap()
+	}
+}
+
+// LockerOptions set options for Locker
+type LockerOptions struct {
+	//Interval between attempts of Lock, default is 50 milliseconds
+	RetryInterval time.Duration
+	//AutoRenew extends obtained locks every third of their ttl until Unlock
+	AutoRenew bool
+}
+
+// Locker distributed mutual exclusion on top of a cache backend.
+// Locks are owned by a random token so only the owner can release or extend them.
+type Locker struct {
+	backend lockBackend
+	opts    LockerOptions
+}
+
+// NewLocker create locker on top of redis client (Standalone, Cluster or Sentinel)
+// or memory cache, also wrapped by Namespace, Instrument, Secure or as remote tier of NewTiered
+func NewLocker(kv Keyval, opts LockerOptions) (*Locker, error) {
+	backend, ok := findLockBackend(kv)
+	if !ok {
+		return nil, ErrLockUnsupported
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = 50 * time.Millisecond
+	}
+	return &Locker{backend: backend, opts: opts}, nil
+}
+
+// Lock obtains the lock of key, retrying until it succeeds or ctx is done
+func (l *Locker) Lock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	if ttl < time.Millisecond {
+		return nil, ErrLockTTL
+	}
+
+	ticker := time.NewTicker(l.opts.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		lock, err := l.TryLock(key, ttl)
+		if err != ErrLockNotObtained {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// TryLock obtains the lock of key once.
+// ErrLockNotObtained is returned if the lock is held by another owner.
+func (l *Locker) TryLock(key string, ttl time.Duration) (*Lock, error) {
+	if ttl < time.Millisecond {
+		return nil, ErrLockTTL
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{
+		backend: l.backend,
+		key:     key,
+		token:   []byte(hex.EncodeToString(token)),
+		ttl:     ttl,
+		lost:    make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+
+	ok, err := l.backend.setNX(lock.key, lock.token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockNotObtained
+	}
+
+	if l.opts.AutoRenew {
+		go lock.renew()
+	}
+
+	return lock, nil
+}
+
+// Lock obtained lock
+type Lock struct {
+	backend lockBackend
+	key     string
+	token   []byte
+
+	mu       sync.Mutex
+	ttl      time.Duration
+	lost     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Key of the lock
+func (lk *Lock) Key() string {
+	return lk.key
+}
+
+// Lost is closed when automatic renewal finds the lock is no longer held
+func (lk *Lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// Unlock releases the lock if it is still owned.
+// ErrLockNotHeld is returned if the lock expired or was taken over.
+func (lk *Lock) Unlock() error {
+	lk.stopOnce.Do(func() {
+		close(lk.stop)
+	})
+
+	ok, err := lk.backend.compareAndDelete(lk.key, lk.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Extend resets the lock expiration to ttl if it is still owned.
+// ErrLockNotHeld is returned if the lock expired or was taken over.
+func (lk *Lock) Extend(ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return ErrLockTTL
+	}
+
+	lk.mu.Lock()
+	lk.ttl = ttl
+	lk.mu.Unlock()
+
+	ok, err := lk.backend.compareAndExpire(lk.key, lk.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (lk *Lock) renew() {
+	for {
+		lk.mu.Lock()
+		ttl := lk.ttl
+		lk.mu.Unlock()
+
+		select {
+		case <-lk.stop:
+			return
+		case <-time.After(ttl / 3):
+		}
+
+		// transient errors are retried on the next tick while the lease is still valid
+		if err := lk.Extend(ttl); err == ErrLockNotHeld {
+			close(lk.lost)
+			return
+		}
+	}
+}
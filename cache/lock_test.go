@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lockBackends(t *testing.T) map[string]Keyval {
+	redis := newStubRedis(t, Standalone)
+	return map[string]Keyval{
+		"memory": NewMemory(MemoryConfig{}),
+		"redis":  redis,
+	}
+}
+
+func TestLocker(t *testing.T) {
+	for name, kv := range lockBackends(t) {
+		locker, err := NewLocker(kv, LockerOptions{RetryInterval: 10 * time.Millisecond})
+		assert.Nil(t, err, name)
+
+		lock, err := locker.TryLock("otp:user1", time.Minute)
+		assert.Nil(t, err, name)
+
+		_, err = locker.TryLock("otp:user1", time.Minute)
+		assert.Equal(t, ErrLockNotObtained, err, name)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err = locker.Lock(ctx, "otp:user1", time.Minute)
+		cancel()
+		assert.Equal(t, context.DeadlineExceeded, err, name)
+
+		assert.Nil(t, lock.Extend(time.Hour), name)
+		assert.Nil(t, lock.Unlock(), name)
+		assert.Equal(t, ErrLockNotHeld, lock.Unlock(), name)
+		assert.Equal(t, ErrLockNotHeld, lock.Extend(time.Hour), name)
+
+		lock, err = locker.Lock(context.Background(), "otp:user1", time.Minute)
+		assert.Nil(t, err, name)
+		lock.Unlock()
+	}
+}
+
+func TestLockerTTL(t *testing.T) {
+	for name, kv := range lockBackends(t) {
+		locker, _ := NewLocker(kv, LockerOptions{})
+
+		for _, ttl := range []time.Duration{0, -time.Second, time.Microsecond} {
+			_, err := locker.TryLock("job", ttl)
+			assert.Equal(t, ErrLockTTL, err, name)
+			_, err = locker.Lock(context.Background(), "job", ttl)
+			assert.Equal(t, ErrLockTTL, err, name)
+		}
+
+		lock, err := locker.TryLock("job", time.Minute)
+		assert.Nil(t, err, name)
+		assert.Equal(t, ErrLockTTL, lock.Extend(0), name)
+
+		//the lock is kept with its previous expiration
+		_, err = locker.TryLock("job", time.Minute)
+		assert.Equal(t, ErrLockNotObtained, err, name)
+		assert.Nil(t, lock.Unlock(), name)
+	}
+}
+
+func TestLockerForeignUnlock(t *testing.T) {
+	kv := NewMemory(MemoryConfig{})
+	locker, _ := NewLocker(kv, LockerOptions{})
+
+	lock, _ := locker.TryLock("job", 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+
+	other, err := locker.TryLock("job", time.Minute)
+	assert.Nil(t, err)
+
+	// expired owner must not release the lock taken over by another owner
+	assert.Equal(t, ErrLockNotHeld, lock.Unlock())
+	assert.Nil(t, other.Unlock())
+}
+
+func TestLockerAutoRenew(t *testing.T) {
+	kv := NewMemory(MemoryConfig{})
+	locker, _ := NewLocker(kv, LockerOptions{AutoRenew: true})
+
+	lock, err := locker.TryLock("job", 60*time.Millisecond)
+	assert.Nil(t, err)
+
+	time.Sleep(150 * time.Millisecond)
+	_, err = locker.TryLock("job", time.Minute)
+	assert.Equal(t, ErrLockNotObtained, err)
+
+	kv.Delete("job")
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Error("lost lock is not reported")
+	}
+
+	_, err = NewLocker(&Mock{}, LockerOptions{})
+	assert.Equal(t, ErrLockUnsupported, err)
+}
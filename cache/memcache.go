@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"context"
+	"strconv"
 	"time"
 
 	"github.com/armiariyan/bepkg/logger"
@@ -9,22 +11,48 @@ import (
 )
 
 type mcache struct {
-	conn   *memcache.Client
-	logger logger.Logger
+	conn    *memcache.Client
+	logger  logger.Logger
+	metrics Metrics
 }
 
-// NewMemcache create new memcache client
-func NewMemcache(servers []string) Keyval {
+// NewMemcache create new memcache client recording to DefaultMetrics
+func NewMemcache(servers []string) KeyvalContext {
+	return NewMemcacheWithMetrics(servers, DefaultMetrics)
+}
+
+// NewMemcacheWithMetrics create new memcache client recording to m
+func NewMemcacheWithMetrics(servers []string, m Metrics) KeyvalContext {
 	mc := memcache.New(servers...)
 	return &mcache{
-		conn: mc,
+		conn:    mc,
+		metrics: m,
 	}
 }
 
+// maxRelativeExpiration memcache treats expirations above 30 days as absolute unix time
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// memcacheExpiration converts expiration to memcache seconds, 0 never expires
+func memcacheExpiration(expiration time.Duration) int32 {
+	if expiration <= 0 {
+		return 0
+	}
+	if expiration > maxRelativeExpiration {
+		return int32(time.Now().Add(expiration).Unix())
+	}
+	//round up, below one second would never expire
+	return int32((expiration + time.Second - 1) / time.Second)
+}
+
 func (m *mcache) SetLogger(l logger.Logger) {
 	m.logger = l
 }
 
+func (m *mcache) observe(operation string, start time.Time, err *error) {
+	observe(m.metrics, "memcache", operation, start, *err)
+}
+
 func (m *mcache) logInfo(method string, message interface{}) {
 	if m.logger != nil {
 		m.logger.Info("|",
@@ -47,11 +75,23 @@ func (m *mcache) logError(method string, message interface{}) {
 	}
 }
 
-// Delete deletes the item with the provided key.
+// Get the item with the provided key.
 // Return nil byte if the item didn't already exist in the cache.
 func (m *mcache) Get(key string) ([]byte, error) {
+	return m.GetCtx(context.Background(), key)
+}
 
-	item, err := m.conn.Get(key)
+// GetCtx is Get which respects the context deadline.
+func (m *mcache) GetCtx(ctx context.Context, key string) (val []byte, err error) {
+	defer func(start time.Time) {
+		observeGet(m.metrics, "memcache", "get", start, val != nil, err)
+	}(time.Now())
+
+	var item *memcache.Item
+	err = doCtx(ctx, func() (err error) {
+		item, err = m.conn.Get(key)
+		return
+	})
 
 	if err == memcache.ErrCacheMiss {
 		//Skip error if no value exist
@@ -64,17 +104,21 @@ func (m *mcache) Get(key string) ([]byte, error) {
 	}
 
 	m.logInfo("Get "+key, item)
-	return item.Value, nil
+	return untagOne(ctx, m, key, item.Value)
 }
 
 // Add writes the given item, if no value already exists for its key.
 // ErrNotStored is returned if that condition is not met.
-func (m *mcache) Add(key string, val []byte, expiration time.Duration) (err error) {
-	err = m.conn.Add(&memcache.Item{Key: key, Value: val})
+func (m *mcache) Add(key string, val []byte, expiration time.Duration) error {
+	return m.AddCtx(context.Background(), key, val, expiration)
+}
 
-	if err == nil {
-		m.conn.Touch(key, int32(expiration*time.Second))
-	}
+// AddCtx is Add which respects the context deadline.
+func (m *mcache) AddCtx(ctx context.Context, key string, val []byte, expiration time.Duration) (err error) {
+	defer m.observe("add", time.Now(), &err)
+	err = doCtx(ctx, func() error {
+		return m.conn.Add(&memcache.Item{Key: key, Value: val, Expiration: memcacheExpiration(expiration)})
+	})
 
 	if err == memcache.ErrNotStored {
 		//Skip error if value exist
@@ -92,11 +136,16 @@ func (m *mcache) Add(key string, val []byte, expiration time.Duration) (err erro
 }
 
 // Set writes the given item, unconditionally.
-func (m *mcache) Set(key string, val []byte, expiration time.Duration) (err error) {
-	err = m.conn.Set(&memcache.Item{Key: key, Value: val})
-	if err == nil {
-		m.conn.Touch(key, int32(expiration*time.Second))
-	}
+func (m *mcache) Set(key string, val []byte, expiration time.Duration) error {
+	return m.SetCtx(context.Background(), key, val, expiration)
+}
+
+// SetCtx is Set which respects the context deadline.
+func (m *mcache) SetCtx(ctx context.Context, key string, val []byte, expiration time.Duration) (err error) {
+	defer m.observe("set", time.Now(), &err)
+	err = doCtx(ctx, func() error {
+		return m.conn.Set(&memcache.Item{Key: key, Value: val, Expiration: memcacheExpiration(expiration)})
+	})
 	if err != nil {
 		m.logError("Set", err)
 		return
@@ -108,7 +157,15 @@ func (m *mcache) Set(key string, val []byte, expiration time.Duration) (err erro
 // Delete deletes the item with the provided key.
 // return nil error if the item didn't already exist in the cache.
 func (m *mcache) Delete(key string) error {
-	err := m.conn.Delete(key)
+	return m.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is Delete which respects the context deadline.
+func (m *mcache) DeleteCtx(ctx context.Context, key string) (err error) {
+	defer m.observe("delete", time.Now(), &err)
+	err = doCtx(ctx, func() error {
+		return m.conn.Delete(key)
+	})
 	if err == memcache.ErrCacheMiss {
 		//Skip error if no value exist
 		return nil
@@ -119,3 +176,193 @@ func (m *mcache) Delete(key string) error {
 	m.logInfo("Delete", key)
 	return err
 }
+
+// GetMulti gets the items with the provided keys.
+// Keys which didn't exist in the cache are omitted from the result.
+func (m *mcache) GetMulti(keys []string) (items map[string][]byte, err error) {
+	defer func(start time.Time) {
+		observeGetMulti(m.metrics, "memcache", "get_multi", start, len(keys), len(items), err)
+	}(time.Now())
+
+	found, err := m.conn.GetMulti(keys)
+	if err != nil {
+		m.logError("GetMulti", err)
+		return nil, err
+	}
+
+	items = make(map[string][]byte, len(found))
+	for key, item := range found {
+		items[key] = item.Value
+	}
+
+	m.logInfo("GetMulti", keys)
+	if err = untag(context.Background(), m, items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// SetMulti writes the given items, unconditionally.
+func (m *mcache) SetMulti(items map[string][]byte, expiration time.Duration) (err error) {
+	defer m.observe("set_multi", time.Now(), &err)
+	for key, val := range items {
+		err = m.conn.Set(&memcache.Item{Key: key, Value: val, Expiration: memcacheExpiration(expiration)})
+		if err != nil {
+			m.logError("SetMulti", err)
+			return
+		}
+	}
+	m.logInfo("SetMulti", len(items))
+	return
+}
+
+// DeleteMulti deletes the items with the provided keys.
+// return nil error if the items didn't already exist in the cache.
+func (m *mcache) DeleteMulti(keys []string) (err error) {
+	defer m.observe("delete_multi", time.Now(), &err)
+	for _, key := range keys {
+		err = m.conn.Delete(key)
+		if err != nil && err != memcache.ErrCacheMiss {
+			m.logError("DeleteMulti", err)
+			return
+		}
+	}
+	m.logInfo("DeleteMulti", keys)
+	return nil
+}
+
+// Incr increments the counter by delta, a missing counter starts from 0.
+// memcache counters are unsigned, decrementing below 0 gives 0.
+func (m *mcache) Incr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return m.incrBy("incr", key, delta, expiration)
+}
+
+// Decr decrements the counter by delta, memcache counters never go below 0
+func (m *mcache) Decr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return m.incrBy("decr", key, -delta, expiration)
+}
+
+func (m *mcache) incrBy(operation, key string, delta int64, expiration time.Duration) (n int64, err error) {
+	defer m.observe(operation, time.Now(), &err)
+	for {
+		var count uint64
+		if delta < 0 {
+			count, err = m.conn.Decrement(key, uint64(-delta))
+		} else {
+			count, err = m.conn.Increment(key, uint64(delta))
+		}
+		if err == nil {
+			m.logInfo("Incr "+key, count)
+			return int64(count), nil
+		}
+		if err != memcache.ErrCacheMiss {
+			m.logError("Incr", err)
+			return 0, err
+		}
+
+		// counter doesn't exist, create it unless another client was faster
+		if delta < 0 {
+			delta = 0
+		}
+		err = m.conn.Add(&memcache.Item{
+			Key:        key,
+			Value:      []byte(strconv.FormatInt(delta, 10)),
+			Expiration: memcacheExpiration(expiration),
+		})
+		if err == nil {
+			m.logInfo("Incr "+key, delta)
+			return delta, nil
+		}
+		if err != memcache.ErrNotStored {
+			m.logError("Incr", err)
+			return 0, err
+		}
+	}
+}
+
+// TTL is not supported by memcache protocol
+func (m *mcache) TTL(key string) (time.Duration, error) {
+	return 0, ErrNotSupported
+}
+
+// Touch resets the expiration without rewriting the value.
+// return nil error if the item didn't already exist in the cache.
+func (m *mcache) Touch(key string, expiration time.Duration) (err error) {
+	defer m.observe("touch", time.Now(), &err)
+	err = m.conn.Touch(key, memcacheExpiration(expiration))
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	if err != nil {
+		m.logError("Touch", err)
+	}
+	return err
+}
+
+// Exists reports whether the key exists
+func (m *mcache) Exists(key string) (ok bool, err error) {
+	defer m.observe("exists", time.Now(), &err)
+	_, err = m.conn.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return false, nil
+	}
+	if err != nil {
+		m.logError("Exists", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// SetWithTags writes the item, Get treats it as missing once any of its tags is invalidated
+func (m *mcache) SetWithTags(key string, val []byte, expiration time.Duration, tags ...string) error {
+	return setWithTags(m, key, val, expiration, tags)
+}
+
+// InvalidateTag invalidates every item written with the tag in O(1)
+func (m *mcache) InvalidateTag(tag string) error {
+	return invalidateTag(m, tag)
+}
+
+// GetWithToken gets the item and its token for CompareAndSwap.
+// Return nil byte if the item didn't already exist in the cache.
+func (m *mcache) GetWithToken(key string) (val []byte, token CASToken, err error) {
+	defer m.observe("get_with_token", time.Now(), &err)
+	item, err := m.conn.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return nil, CASToken{}, nil
+	}
+	if err != nil {
+		m.logError("GetWithToken", err)
+		return nil, CASToken{}, err
+	}
+	val, err = untagOne(context.Background(), m, key, item.Value)
+	return val, CASToken{item: item}, err
+}
+
+// CompareAndSwap writes val only if the item didn't change since GetWithToken,
+// ErrCASConflict otherwise.
+func (m *mcache) CompareAndSwap(key string, val []byte, expiration time.Duration, token CASToken) (err error) {
+	defer m.observe("compare_and_swap", time.Now(), &err)
+	if token.item == nil {
+		err = m.conn.Add(&memcache.Item{Key: key, Value: val, Expiration: memcacheExpiration(expiration)})
+	} else {
+		item := *token.item
+		item.Value = val
+		item.Expiration = memcacheExpiration(expiration)
+		err = m.conn.CompareAndSwap(&item)
+	}
+
+	switch err {
+	case nil:
+		m.logInfo("CompareAndSwap "+key, string(val))
+	case memcache.ErrCASConflict, memcache.ErrNotStored, memcache.ErrCacheMiss:
+		err = ErrCASConflict
+	default:
+		m.logError("CompareAndSwap", err)
+	}
+	return
+}
+
+func (m *mcache) cacheLogger() logger.Logger {
+	return m.logger
+}
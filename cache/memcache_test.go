@@ -4,8 +4,21 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/armiariyan/bepkg/cache/internal/memcachestub"
+	"github.com/stretchr/testify/assert"
 )
 
+// newStubMemcache returns client of an in-process memcache stand-in closed with the test
+func newStubMemcache(t *testing.T) *mcache {
+	s, err := memcachestub.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return NewMemcache([]string{s.Addr()}).(*mcache)
+}
+
 func TestSet(t *testing.T) {
 
 	x := NewMemcache([]string{"0.0.0.0:9002"})
@@ -36,3 +49,14 @@ func TestDelete(t *testing.T) {
 	err := x.Delete("test")
 	fmt.Println(err)
 }
+
+func TestMemcacheExpiration(t *testing.T) {
+	assert.Equal(t, int32(0), memcacheExpiration(0))
+	assert.Equal(t, int32(0), memcacheExpiration(NoExpiration))
+	assert.Equal(t, int32(1), memcacheExpiration(300*time.Millisecond))
+	assert.Equal(t, int32(3600), memcacheExpiration(time.Hour))
+	assert.Equal(t, int32(30*24*3600), memcacheExpiration(maxRelativeExpiration))
+
+	abs := memcacheExpiration(60 * 24 * time.Hour)
+	assert.InDelta(t, time.Now().Add(60*24*time.Hour).Unix(), int64(abs), 2)
+}
@@ -0,0 +1,385 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/armiariyan/bepkg/logger"
+)
+
+// MemoryConfig set config for in-process memory cache
+type MemoryConfig struct {
+	//Maximum number of entries kept, 0 means unlimited
+	MaxEntries int
+	//Maximum total size of keys and values in bytes, 0 means unlimited.
+	//Larger items are rejected with ErrTooLarge
+	MaxBytes int64
+	//Interval of the background sweeper removing expired entries,
+	//default is one minute, negative value disables the sweeper
+	SweepInterval time.Duration
+}
+
+// ErrTooLarge returned by memory cache writes of an item larger than MaxBytes
+var ErrTooLarge = errors.New("cache: item larger than MaxBytes")
+
+type memEntry struct {
+	key      string
+	val      []byte
+	expireAt time.Time
+}
+
+func (e *memEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+func (e *memEntry) size() int64 {
+	return int64(len(e.key) + len(e.val))
+}
+
+type lcache struct {
+	mu      sync.Mutex
+	cfg     MemoryConfig
+	items   map[string]*list.Element
+	lru     *list.List
+	size    int64
+	logger  logger.Logger
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+// NewMemory create in-process LRU cache with per key expiration.
+// The returned Keyval also implements io.Closer, Close it once the cache is no longer used,
+// otherwise the background sweeper keeps running and the cache is never released.
+func NewMemory(cfg MemoryConfig) Keyval {
+	m := &lcache{
+		cfg:   cfg,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+		stop:  make(chan struct{}),
+	}
+
+	interval := cfg.SweepInterval
+	if interval == 0 {
+		interval = time.Minute
+	}
+	if interval > 0 {
+		go m.sweep(interval)
+	}
+
+	return m
+}
+
+func (m *lcache) SetLogger(l logger.Logger) {
+	m.logger = l
+}
+
+// Close stops the background sweeper
+func (m *lcache) Close() error {
+	m.stopped.Do(func() {
+		close(m.stop)
+	})
+	return nil
+}
+
+func (m *lcache) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			for e := m.lru.Back(); e != nil; {
+				prev := e.Prev()
+				if e.Value.(*memEntry).expired(now) {
+					m.removeElement(e)
+				}
+				e = prev
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+// lookup returns the live element of key, expired element is removed.
+// caller must hold the lock
+func (m *lcache) lookup(key string, now time.Time) *list.Element {
+	e, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+	if e.Value.(*memEntry).expired(now) {
+		m.removeElement(e)
+		return nil
+	}
+	return e
+}
+
+func (m *lcache) removeElement(e *list.Element) {
+	entry := m.lru.Remove(e).(*memEntry)
+	delete(m.items, entry.key)
+	m.size -= entry.size()
+}
+
+// store writes the entry and evicts least recently used entries when the bound is exceeded.
+// An entry larger than MaxBytes is rejected and the previous entry of key is removed.
+// caller must hold the lock
+func (m *lcache) store(key string, val []byte, expiration time.Duration, now time.Time) error {
+	if e, ok := m.items[key]; ok {
+		m.removeElement(e)
+	}
+
+	entry := &memEntry{key: key}
+	if m.cfg.MaxBytes > 0 && entry.size()+int64(len(val)) > m.cfg.MaxBytes {
+		return ErrTooLarge
+	}
+	entry.val = append([]byte(nil), val...)
+	if expiration > 0 {
+		entry.expireAt = now.Add(expiration)
+	}
+
+	m.items[key] = m.lru.PushFront(entry)
+	m.size += entry.size()
+
+	for m.lru.Len() > 1 && m.overflow() {
+		m.removeElement(m.lru.Back())
+	}
+	return nil
+}
+
+func (m *lcache) overflow() bool {
+	if m.cfg.MaxEntries > 0 && m.lru.Len() > m.cfg.MaxEntries {
+		return true
+	}
+	return m.cfg.MaxBytes > 0 && m.size > m.cfg.MaxBytes
+}
+
+// Get the item with the provided key.
+// Return nil byte if the item didn't already exist in the cache.
+func (m *lcache) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.lookup(key, time.Now())
+	if e == nil {
+		return nil, nil
+	}
+	m.lru.MoveToFront(e)
+
+	return append([]byte(nil), e.Value.(*memEntry).val...), nil
+}
+
+// Add writes the given item, if no value already exists for its key.
+func (m *lcache) Add(key string, val []byte, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.lookup(key, now) != nil {
+		return nil
+	}
+
+	return m.store(key, val, expiration, now)
+}
+
+// Set writes the given item, unconditionally.
+func (m *lcache) Set(key string, val []byte, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.store(key, val, expiration, time.Now())
+}
+
+// Delete deletes the item with the provided key.
+// return nil error if the item didn't already exist in the cache.
+func (m *lcache) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.items[key]; ok {
+		m.removeElement(e)
+	}
+
+	return nil
+}
+
+// GetMulti gets the items with the provided keys.
+// Keys which didn't exist in the cache are omitted from the result.
+func (m *lcache) GetMulti(keys []string) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	items := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if e := m.lookup(key, now); e != nil {
+			m.lru.MoveToFront(e)
+			items[key] = append([]byte(nil), e.Value.(*memEntry).val...)
+		}
+	}
+
+	return items, nil
+}
+
+// SetMulti writes the given items, unconditionally.
+// Items larger than MaxBytes are skipped and ErrTooLarge is returned.
+func (m *lcache) SetMulti(items map[string][]byte, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var err error
+	for key, val := range items {
+		if serr := m.store(key, val, expiration, now); serr != nil {
+			err = serr
+		}
+	}
+
+	return err
+}
+
+// DeleteMulti deletes the items with the provided keys.
+func (m *lcache) DeleteMulti(keys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := m.items[key]; ok {
+			m.removeElement(e)
+		}
+	}
+
+	return nil
+}
+
+// setNX writes the item only if the key does not exist and reports whether it was stored
+func (m *lcache) setNX(key string, val []byte, expiration time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.lookup(key, now) != nil {
+		return false, nil
+	}
+	if err := m.store(key, val, expiration, now); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// compareAndDelete deletes the key only if it holds val
+func (m *lcache) compareAndDelete(key string, val []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.lookup(key, time.Now())
+	if e == nil || !bytes.Equal(e.Value.(*memEntry).val, val) {
+		return false, nil
+	}
+	m.removeElement(e)
+
+	return true, nil
+}
+
+// compareAndExpire sets new expiration of the key only if it holds val
+func (m *lcache) compareAndExpire(key string, val []byte, expiration time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e := m.lookup(key, now)
+	if e == nil || !bytes.Equal(e.Value.(*memEntry).val, val) {
+		return false, nil
+	}
+	e.Value.(*memEntry).expireAt = now.Add(expiration)
+
+	return true, nil
+}
+
+// Incr increments the counter by delta, a missing counter starts from 0.
+// Counters are stored as decimal strings like redis does.
+func (m *lcache) Incr(key string, delta int64, expiration time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var count int64
+	var expireAt time.Time
+
+	if e := m.lookup(key, now); e != nil {
+		entry := e.Value.(*memEntry)
+		var err error
+		if count, err = strconv.ParseInt(string(entry.val), 10, 64); err != nil {
+			return 0, errors.New("value is not an integer")
+		}
+		expireAt = entry.expireAt
+	}
+	count += delta
+
+	if err := m.store(key, []byte(strconv.FormatInt(count, 10)), expiration, now); err != nil {
+		return 0, err
+	}
+	if !expireAt.IsZero() {
+		m.items[key].Value.(*memEntry).expireAt = expireAt
+	}
+
+	return count, nil
+}
+
+// Decr decrements the counter by delta, a missing counter starts from 0
+func (m *lcache) Decr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return m.Incr(key, -delta, expiration)
+}
+
+// TTL returns remaining time to live
+func (m *lcache) TTL(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e := m.lookup(key, now)
+	if e == nil {
+		return 0, nil
+	}
+	entry := e.Value.(*memEntry)
+	if entry.expireAt.IsZero() {
+		return NoExpiration, nil
+	}
+	return entry.expireAt.Sub(now), nil
+}
+
+// Touch resets the expiration without rewriting the value
+func (m *lcache) Touch(key string, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if e := m.lookup(key, now); e != nil {
+		entry := e.Value.(*memEntry)
+		entry.expireAt = time.Time{}
+		if expiration > 0 {
+			entry.expireAt = now.Add(expiration)
+		}
+	}
+
+	return nil
+}
+
+// Exists reports whether the key exists
+func (m *lcache) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lookup(key, time.Now()) != nil, nil
+}
+
+func (m *lcache) cacheLogger() logger.Logger {
+	return m.logger
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryGetMiss(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+
+	b, err := x.Get("missing")
+	assert.Nil(t, b)
+	assert.Nil(t, err)
+}
+
+func TestMemoryAddSet(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+
+	assert.Nil(t, x.Add("test", []byte("ini isi test"), time.Hour))
+	assert.Nil(t, x.Add("test", []byte("ini lagi"), time.Hour))
+
+	b, _ := x.Get("test")
+	assert.Equal(t, "ini isi test", string(b))
+
+	assert.Nil(t, x.Set("test", []byte("ini lagi"), time.Hour))
+
+	b, _ = x.Get("test")
+	assert.Equal(t, "ini lagi", string(b))
+
+	assert.Nil(t, x.Delete("test"))
+	assert.Nil(t, x.Delete("test"))
+
+	b, _ = x.Get("test")
+	assert.Nil(t, b)
+}
+
+func TestMemoryExpiration(t *testing.T) {
+	x := NewMemory(MemoryConfig{SweepInterval: 10 * time.Millisecond})
+	defer x.(*lcache).Close()
+
+	x.Set("short", []byte("a"), 20*time.Millisecond)
+	x.Set("forever", []byte("b"), 0)
+
+	time.Sleep(50 * time.Millisecond)
+
+	b, _ := x.Get("short")
+	assert.Nil(t, b)
+	b, _ = x.Get("forever")
+	assert.Equal(t, "b", string(b))
+
+	// expired key can be added again
+	assert.Nil(t, x.Add("short", []byte("c"), time.Hour))
+	b, _ = x.Get("short")
+	assert.Equal(t, "c", string(b))
+}
+
+func TestMemoryEviction(t *testing.T) {
+	x := NewMemory(MemoryConfig{MaxEntries: 2})
+
+	x.Set("a", []byte("1"), 0)
+	x.Set("b", []byte("2"), 0)
+	x.Get("a")
+	x.Set("c", []byte("3"), 0)
+
+	b, _ := x.Get("b")
+	assert.Nil(t, b)
+	b, _ = x.Get("a")
+	assert.Equal(t, "1", string(b))
+
+	y := NewMemory(MemoryConfig{MaxBytes: 7})
+	y.Set("a", []byte("123"), 0)
+	y.Set("b", []byte("456"), 0)
+
+	b, _ = y.Get("a")
+	assert.Nil(t, b)
+	b, _ = y.Get("b")
+	assert.Equal(t, "456", string(b))
+}
+
+func TestMemoryTooLarge(t *testing.T) {
+	x := NewMemory(MemoryConfig{MaxBytes: 8})
+	defer x.(interface{ Close() error }).Close()
+
+	x.Set("a", []byte("1"), 0)
+	x.Set("b", []byte("2"), 0)
+
+	//a rejected item evicts nothing and leaves no stale value behind
+	assert.Equal(t, ErrTooLarge, x.Set("b", []byte("12345678"), 0))
+	b, _ := x.Get("b")
+	assert.Nil(t, b)
+	b, _ = x.Get("a")
+	assert.Equal(t, "1", string(b))
+
+	assert.Equal(t, ErrTooLarge, x.Add("c", []byte("12345678"), 0))
+	assert.Equal(t, ErrTooLarge, x.SetMulti(map[string][]byte{"d": []byte("4"), "e": []byte("12345678")}, 0))
+	b, _ = x.Get("d")
+	assert.Equal(t, "4", string(b))
+}
+
+func TestMemoryConcurrent(t *testing.T) {
+	x := NewMemory(MemoryConfig{MaxEntries: 50})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				key := fmt.Sprintf("key-%d", j%100)
+				x.Set(key, []byte(key), time.Minute)
+				x.Get(key)
+				if j%7 == 0 {
+					x.Delete(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.True(t, x.(*lcache).lru.Len() <= 50)
+}
+
+func TestMemoryMulti(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+
+	x.SetMulti(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, time.Hour)
+
+	items, err := x.GetMulti([]string{"a", "b", "c"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, items)
+
+	x.DeleteMulti([]string{"a", "c"})
+	items, _ = x.GetMulti([]string{"a", "b"})
+	assert.Equal(t, map[string][]byte{"b": []byte("2")}, items)
+}
+
+func TestMemoryCounters(t *testing.T) {
+	x := NewMemory(MemoryConfig{})
+
+	n, err := x.Incr("attempt", 1, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	n, _ = x.Incr("attempt", 5, time.Hour)
+	assert.Equal(t, int64(6), n)
+	n, _ = x.Decr("attempt", 2, 0)
+	assert.Equal(t, int64(4), n)
+
+	b, _ := x.Get("attempt")
+	assert.Equal(t, "4", string(b))
+
+	ttl, _ := x.TTL("attempt")
+	assert.True(t, ttl > 59*time.Second && ttl <= time.Minute)
+
+	x.Touch("attempt", time.Hour)
+	ttl, _ = x.TTL("attempt")
+	assert.True(t, ttl > 59*time.Minute)
+
+	x.Touch("attempt", 0)
+	ttl, _ = x.TTL("attempt")
+	assert.Equal(t, NoExpiration, ttl)
+
+	ttl, _ = x.TTL("missing")
+	assert.Equal(t, time.Duration(0), ttl)
+
+	ok, _ := x.Exists("attempt")
+	assert.True(t, ok)
+	ok, _ = x.Exists("missing")
+	assert.False(t, ok)
+
+	x.Set("text", []byte("abc"), 0)
+	_, err = x.Incr("text", 1, 0)
+	assert.NotNil(t, err)
+}
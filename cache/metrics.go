@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operation results recorded by Metrics
+const (
+	ResultHit   = "hit"
+	ResultMiss  = "miss"
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// Metrics collects cache operation metrics.
+// Multi-key reads are observed once per requested key so hits and misses count keys.
+type Metrics interface {
+	Observe(backend, operation, result string, latency time.Duration)
+}
+
+// DefaultMetrics metrics recorded by redis and memcache clients and by Instrument.
+// Serve it on the metrics endpoint, e.g. e.GET("/metrics", echo.WrapHandler(cache.DefaultMetrics)).
+var DefaultMetrics = NewPrometheusMetrics(nil)
+
+// DefaultLatencyBuckets upper bounds of latency histogram buckets in seconds
+var DefaultLatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+type opKey struct {
+	backend, operation string
+}
+
+type counterKey struct {
+	opKey
+	result string
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// PrometheusMetrics in-process Metrics exposed in prometheus text format
+type PrometheusMetrics struct {
+	mu         sync.Mutex
+	bounds     []float64
+	counters   map[counterKey]uint64
+	histograms map[opKey]*histogram
+}
+
+// NewPrometheusMetrics create metrics with latency bucket upper bounds in seconds,
+// nil bounds uses DefaultLatencyBuckets
+func NewPrometheusMetrics(bounds []float64) *PrometheusMetrics {
+	if bounds == nil {
+		bounds = DefaultLatencyBuckets
+	}
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+
+	return &PrometheusMetrics{
+		bounds:     bounds,
+		counters:   make(map[counterKey]uint64),
+		histograms: make(map[opKey]*histogram),
+	}
+}
+
+// Observe records the operation result and latency
+func (p *PrometheusMetrics) Observe(backend, operation, result string, latency time.Duration) {
+	op := opKey{backend: backend, operation: operation}
+	seconds := latency.Seconds()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.counters[counterKey{opKey: op, result: result}]++
+
+	h, ok := p.histograms[op]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(p.bounds))}
+		p.histograms[op] = h
+	}
+	for i, bound := range p.bounds {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Count returns the number of operations recorded with the result
+func (p *PrometheusMetrics) Count(backend, operation, result string) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.counters[counterKey{opKey: opKey{backend: backend, operation: operation}, result: result}]
+}
+
+// WriteTo writes the metrics in prometheus text exposition format
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var sb strings.Builder
+
+	counters := make([]counterKey, 0, len(p.counters))
+	for k := range p.counters {
+		counters = append(counters, k)
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		a, b := counters[i], counters[j]
+		if a.opKey != b.opKey {
+			return lessOp(a.opKey, b.opKey)
+		}
+		return a.result < b.result
+	})
+
+	sb.WriteString("# HELP cache_operations_total Cache operations by backend, operation and result.\n")
+	sb.WriteString("# TYPE cache_operations_total counter\n")
+	for _, k := range counters {
+		fmt.Fprintf(&sb, "cache_operations_total{backend=%q,operation=%q,result=%q} %d\n",
+			k.backend, k.operation, k.result, p.counters[k])
+	}
+
+	ops := make([]opKey, 0, len(p.histograms))
+	for k := range p.histograms {
+		ops = append(ops, k)
+	}
+	sort.Slice(ops, func(i, j int) bool { return lessOp(ops[i], ops[j]) })
+
+	sb.WriteString("# HELP cache_operation_duration_seconds Cache operation latency by backend and operation.\n")
+	sb.WriteString("# TYPE cache_operation_duration_seconds histogram\n")
+	for _, k := range ops {
+		h := p.histograms[k]
+		for i, bound := range p.bounds {
+			fmt.Fprintf(&sb, "cache_operation_duration_seconds_bucket{backend=%q,operation=%q,le=%q} %d\n",
+				k.backend, k.operation, strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(&sb, "cache_operation_duration_seconds_bucket{backend=%q,operation=%q,le=\"+Inf\"} %d\n",
+			k.backend, k.operation, h.count)
+		fmt.Fprintf(&sb, "cache_operation_duration_seconds_sum{backend=%q,operation=%q} %s\n",
+			k.backend, k.operation, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&sb, "cache_operation_duration_seconds_count{backend=%q,operation=%q} %d\n",
+			k.backend, k.operation, h.count)
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// ServeHTTP exposes the metrics for prometheus scraping
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+func lessOp(a, b opKey) bool {
+	if a.backend != b.backend {
+		return a.backend < b.backend
+	}
+	return a.operation < b.operation
+}
+
+// observe records result of an operation started at start
+func observe(m Metrics, backend, operation string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	result := ResultOK
+	if err != nil {
+		result = ResultError
+	}
+	m.Observe(backend, operation, result, time.Since(start))
+}
+
+// observeGet records hit or miss of a read operation started at start
+func observeGet(m Metrics, backend, operation string, start time.Time, found bool, err error) {
+	if m == nil {
+		return
+	}
+	result := ResultMiss
+	switch {
+	case err != nil:
+		result = ResultError
+	case found:
+		result = ResultHit
+	}
+	m.Observe(backend, operation, result, time.Since(start))
+}
+
+// observeGetMulti records hit or miss of every requested key of a multi-key read started at start
+func observeGetMulti(m Metrics, backend, operation string, start time.Time, requested, found int, err error) {
+	if m == nil {
+		return
+	}
+	latency := time.Since(start)
+	if err != nil {
+		m.Observe(backend, operation, ResultError, latency)
+		return
+	}
+	for i := 0; i < requested; i++ {
+		result := ResultMiss
+		if i < found {
+			result = ResultHit
+		}
+		m.Observe(backend, operation, result, latency)
+	}
+}
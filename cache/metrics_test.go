@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsObserve(t *testing.T) {
+	m := NewPrometheusMetrics([]float64{.01, .1})
+
+	m.Observe("redis", "get", ResultHit, 5*time.Millisecond)
+	m.Observe("redis", "get", ResultMiss, 50*time.Millisecond)
+	m.Observe("redis", "get", ResultHit, time.Second)
+
+	assert.Equal(t, uint64(2), m.Count("redis", "get", ResultHit))
+	assert.Equal(t, uint64(1), m.Count("redis", "get", ResultMiss))
+	assert.Equal(t, uint64(0), m.Count("redis", "get", ResultError))
+
+	var buf bytes.Buffer
+	_, err := m.WriteTo(&buf)
+	assert.Nil(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, `cache_operations_total{backend="redis",operation="get",result="hit"} 2`)
+	assert.Contains(t, out, `cache_operation_duration_seconds_bucket{backend="redis",operation="get",le="0.01"} 1`)
+	assert.Contains(t, out, `cache_operation_duration_seconds_bucket{backend="redis",operation="get",le="0.1"} 2`)
+	assert.Contains(t, out, `cache_operation_duration_seconds_bucket{backend="redis",operation="get",le="+Inf"} 3`)
+	assert.Contains(t, out, `cache_operation_duration_seconds_count{backend="redis",operation="get"} 3`)
+}
+
+func TestMetricsServeHTTP(t *testing.T) {
+	m := NewPrometheusMetrics(nil)
+	m.Observe("memcache", "set", ResultOK, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, rec.Body.String(), `cache_operations_total{backend="memcache",operation="set",result="ok"} 1`)
+}
+
+func TestMetricsObserveError(t *testing.T) {
+	m := NewPrometheusMetrics(nil)
+
+	observe(m, "redis", "set", time.Now(), errors.New("broken pipe"))
+	observeGet(m, "redis", "get", time.Now(), false, nil)
+	observe(nil, "redis", "set", time.Now(), nil)
+
+	assert.Equal(t, uint64(1), m.Count("redis", "set", ResultError))
+	assert.Equal(t, uint64(1), m.Count("redis", "get", ResultMiss))
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/armiariyan/bepkg/logger"
+)
+
+// namespace Keyval decorator prefixing every key
+type namespace struct {
+	kv     Keyval
+	prefix string
+}
+
+// lockNamespace namespace of a backend usable by NewLocker
+type lockNamespace struct {
+	*namespace
+	lb lockBackend
+}
+
+// Namespace prefixes every key of kv with prefix and ":", e.g. services sharing one redis.
+// Bump a version in the prefix, e.g. "orders:v2", to drop every key of the previous version.
+// Tags are namespaced as well, SetWithTags and InvalidateTag return ErrNotSupported when kv isn't a Tagger.
+func Namespace(kv Keyval, prefix string) Keyval {
+	ns := &namespace{kv: kv, prefix: prefix + ":"}
+	if lb, ok := findLockBackend(kv); ok {
+		return &lockNamespace{namespace: ns, lb: lb}
+	}
+	return ns
+}
+
+func (n *namespace) key(key string) string {
+	return n.prefix + key
+}
+
+func (n *namespace) keys(keys []string) []string {
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixed = append(prefixed, n.key(key))
+	}
+	return prefixed
+}
+
+func (n *namespace) SetLogger(l logger.Logger) {
+	n.kv.SetLogger(l)
+}
+
+func (n *namespace) Add(key string, val []byte, expiration time.Duration) error {
+	return n.kv.Add(n.key(key), val, expiration)
+}
+
+func (n *namespace) Set(key string, val []byte, expiration time.Duration) error {
+	return n.kv.Set(n.key(key), val, expiration)
+}
+
+func (n *namespace) Delete(key string) error {
+	return n.kv.Delete(n.key(key))
+}
+
+func (n *namespace) Get(key string) ([]byte, error) {
+	return n.kv.Get(n.key(key))
+}
+
+func (n *namespace) GetMulti(keys []string) (map[string][]byte, error) {
+	return n.trim(n.kv.GetMulti(n.keys(keys)))
+}
+
+// trim removes the prefix from keys of found items
+func (n *namespace) trim(found map[string][]byte, err error) (map[string][]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string][]byte, len(found))
+	for key, val := range found {
+		items[strings.TrimPrefix(key, n.prefix)] = val
+	}
+	return items, nil
+}
+
+func (n *namespace) SetMulti(items map[string][]byte, expiration time.Duration) error {
+	prefixed := make(map[string][]byte, len(items))
+	for key, val := range items {
+		prefixed[n.key(key)] = val
+	}
+	return n.kv.SetMulti(prefixed, expiration)
+}
+
+func (n *namespace) DeleteMulti(keys []string) error {
+	return n.kv.DeleteMulti(n.keys(keys))
+}
+
+func (n *namespace) Incr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return n.kv.Incr(n.key(key), delta, expiration)
+}
+
+func (n *namespace) Decr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return n.kv.Decr(n.key(key), delta, expiration)
+}
+
+func (n *namespace) TTL(key string) (time.Duration, error) {
+	return n.kv.TTL(n.key(key))
+}
+
+func (n *namespace) Touch(key string, expiration time.Duration) error {
+	return n.kv.Touch(n.key(key), expiration)
+}
+
+func (n *namespace) Exists(key string) (bool, error) {
+	return n.kv.Exists(n.key(key))
+}
+
+// SetWithTags writes the item with namespaced tags
+func (n *namespace) SetWithTags(key string, val []byte, expiration time.Duration, tags ...string) error {
+	t, ok := n.kv.(Tagger)
+	if !ok {
+		return ErrNotSupported
+	}
+	return t.SetWithTags(n.key(key), val, expiration, n.keys(tags)...)
+}
+
+// InvalidateTag invalidates every item of the namespace written with the tag
+func (n *namespace) InvalidateTag(tag string) error {
+	t, ok := n.kv.(Tagger)
+	if !ok {
+		return ErrNotSupported
+	}
+	return t.InvalidateTag(n.key(tag))
+}
+
+// Close closes the wrapped cache if it is closable
+func (n *namespace) Close() error {
+	if c, ok := n.kv.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (n *lockNamespace) setNX(key string, val []byte, expiration time.Duration) (bool, error) {
+	return n.lb.setNX(n.key(key), val, expiration)
+}
+
+func (n *lockNamespace) compareAndDelete(key string, val []byte) (bool, error) {
+	return n.lb.compareAndDelete(n.key(key), val)
+}
+
+func (n *lockNamespace) compareAndExpire(key string, val []byte, expiration time.Duration) (bool, error) {
+	return n.lb.compareAndExpire(n.key(key), val, expiration)
+}
+
+func (n *namespace) cacheLogger() logger.Logger {
+	return kvLogger(n.kv)
+}
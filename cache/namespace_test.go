@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespace(t *testing.T) {
+	kv := NewMemory(MemoryConfig{})
+	orders := Namespace(kv, "orders")
+	users := Namespace(kv, "users")
+
+	assert.Nil(t, orders.Set("1", []byte("paid"), time.Hour))
+	assert.Nil(t, users.Set("1", []byte("lorem"), time.Hour))
+
+	b, _ := kv.Get("orders:1")
+	assert.Equal(t, "paid", string(b))
+	b, _ = users.Get("1")
+	assert.Equal(t, "lorem", string(b))
+
+	assert.Nil(t, orders.SetMulti(map[string][]byte{"2": []byte("new")}, time.Hour))
+	items, err := orders.GetMulti([]string{"1", "2", "3"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"1": []byte("paid"), "2": []byte("new")}, items)
+
+	n, _ := orders.Incr("seq", 2, time.Hour)
+	assert.Equal(t, int64(2), n)
+	ok, _ := kv.Exists("orders:seq")
+	assert.True(t, ok)
+
+	assert.Equal(t, ErrNotSupported, orders.(Tagger).InvalidateTag("merchant:7"))
+
+	locker, err := NewLocker(orders, LockerOptions{})
+	assert.Nil(t, err)
+	lock, err := locker.Lock(context.Background(), "job", time.Minute)
+	assert.Nil(t, err)
+	ok, _ = kv.Exists("orders:job")
+	assert.True(t, ok)
+	assert.Nil(t, lock.Unlock())
+}
+
+func TestNamespaceTags(t *testing.T) {
+	x := newStubRedis(t, Standalone)
+	a := Namespace(x, "a").(Tagger)
+	b := Namespace(x, "b").(Tagger)
+
+	assert.Nil(t, a.SetWithTags("1", []byte("a"), time.Hour, "merchant:7"))
+	assert.Nil(t, b.SetWithTags("1", []byte("b"), time.Hour, "merchant:7"))
+
+	assert.Nil(t, a.InvalidateTag("merchant:7"))
+
+	v, _ := x.Get("a:1")
+	assert.Nil(t, v)
+	v, _ = x.Get("b:1")
+	assert.Equal(t, "b", string(v))
+}
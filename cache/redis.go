@@ -1,8 +1,10 @@
 package cache
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/armiariyan/bepkg/logger"
@@ -13,36 +15,46 @@ type rcache struct {
 	client       radix.Client
 	sentinelConn *radix.Sentinel
 	logger       logger.Logger
+	metrics      Metrics
+
+	//used to open dedicated connections, e.g. for pub/sub
+	connFunc radix.ConnFunc
+	servers  []string
+	topology Topology
+	poolSize int
 }
 
 // NewRedis create redis client
-func NewRedis(cfg Config) (kv Keyval, err error) {
+func NewRedis(cfg Config) (kv RedisClient, err error) {
 	var conn radix.Client
 	var sentinelConn *radix.Sentinel
-	var opts []radix.DialOpt
-	var customConnFunc func(network, addr string) (radix.Conn, error)
+	var customConnFunc radix.ConnFunc
 	var TopologyType Topology
 	var useOpts bool
 	//Default Pool Size
 	poolSize := 4
 
+	if cfg, err = cfg.withURL(); err != nil {
+		return
+	}
+	if cfg.Topology == Cluster && cfg.DB != 0 {
+		return nil, errors.New("redis cluster supports DB 0 only")
+	}
+
 	// This is a ConnFunc which will set up a connection which is authenticated
 	// and has a timeout on all operations
 	if cfg.PoolSize != 0 {
 		poolSize = cfg.PoolSize
 		useOpts = true
 	}
-	if cfg.Timeout != 0 {
-		opts = append(opts, radix.DialTimeout(cfg.Timeout))
-		useOpts = true
+	opts, err := cfg.dialOpts()
+	if err != nil {
+		return
 	}
-	if cfg.AuthPass != "" {
-		opts = append(opts, radix.DialAuthPass(cfg.AuthPass))
+	if len(opts) > 0 || cfg.Username != "" || cfg.AuthPass != "" || cfg.DB != 0 {
 		useOpts = true
 	}
-	customConnFunc = func(network, addr string) (radix.Conn, error) {
-		return radix.Dial(network, addr, opts...)
-	}
+	customConnFunc = cfg.connFunc(opts)
 
 	TopologyType = cfg.Topology
 	servers := cfg.Servers
@@ -72,6 +84,12 @@ func NewRedis(cfg Config) (kv Keyval, err error) {
 				return radix.NewPool(network, addr, poolSize, radix.PoolConnFunc(customConnFunc))
 			}))
 		}
+		if len(opts) > 0 {
+			// sentinels are dialed with the same timeouts and TLS, without credentials of the primary
+			sopt = append(sopt, radix.SentinelConnFunc(func(network, addr string) (radix.Conn, error) {
+				return radix.Dial(network, addr, opts...)
+			}))
+		}
 		if len(cfg.Sentinel.Addrs) == 0 || cfg.Sentinel.PrimaryName == "" {
 			return nil, errors.New("Incomplete redis sentinels configuration")
 		}
@@ -85,9 +103,18 @@ func NewRedis(cfg Config) (kv Keyval, err error) {
 		return
 	}
 
+	metrics := cfg.Metrics
+	if metrics == nil {
+		metrics = DefaultMetrics
+	}
 	kv = &rcache{
 		client:       conn,
 		sentinelConn: sentinelConn,
+		connFunc:     customConnFunc,
+		servers:      servers,
+		topology:     TopologyType,
+		poolSize:     poolSize,
+		metrics:      metrics,
 	}
 	return
 }
@@ -96,6 +123,10 @@ func (m *rcache) SetLogger(l logger.Logger) {
 	m.logger = l
 }
 
+func (m *rcache) observe(operation string, start time.Time, err *error) {
+	observe(m.metrics, "redis", operation, start, *err)
+}
+
 func (m *rcache) logInfo(message interface{}) {
 	if m.logger != nil {
 		m.logger.Info("redis-cache",
@@ -116,18 +147,37 @@ func (m *rcache) logError(message interface{}) {
 
 // Get the item with the provided key.
 // Return nil byte if the item didn't already exist in the cache.
-func (m *rcache) Get(key string) (rcv []byte, err error) {
-	err = m.client.Do(radix.Cmd(&rcv, "GET", key))
+func (m *rcache) Get(key string) ([]byte, error) {
+	return m.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get which respects the context deadline.
+func (m *rcache) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	start := time.Now()
+	var rcv []byte
+	err := doCtx(ctx, func() error {
+		return m.client.Do(radix.Cmd(&rcv, "GET", key))
+	})
+	if err == nil {
+		rcv, err = untagOne(ctx, m, key, rcv)
+	}
+	observeGet(m.metrics, "redis", "get", start, err == nil && rcv != nil, err)
 	if err != nil {
-		m.logError(fmt.Sprintf("%s %s %s", key, string(rcv), err.Error()))
-		return
+		m.logError(fmt.Sprintf("%s %s", key, err.Error()))
+		return nil, err
 	}
-	return
+	return rcv, nil
 }
 
 // Add writes the given item, if no value already exists for its key.
 // ErrNotStored is returned if that condition is not met.
-func (m *rcache) Add(key string, val []byte, expiration time.Duration) (err error) {
+func (m *rcache) Add(key string, val []byte, expiration time.Duration) error {
+	return m.AddCtx(context.Background(), key, val, expiration)
+}
+
+// AddCtx is Add which respects the context deadline.
+func (m *rcache) AddCtx(ctx context.Context, key string, val []byte, expiration time.Duration) (err error) {
+	defer m.observe("add", time.Now(), &err)
 
 	args := []string{key, string(val)}
 
@@ -139,7 +189,9 @@ func (m *rcache) Add(key string, val []byte, expiration time.Duration) (err erro
 		//PX milliseconds -- Set the specified expire time, in milliseconds.
 		args = append(args, "EX", fmt.Sprintf("%d", int(expiration.Seconds())))
 	}
-	err = m.client.Do(radix.Cmd(nil, "SET", args...))
+	err = doCtx(ctx, func() error {
+		return m.client.Do(radix.Cmd(nil, "SET", args...))
+	})
 	if err != nil {
 		m.logError(fmt.Sprintf("%s %s %s", key, string(val), err.Error()))
 		return
@@ -149,7 +201,13 @@ func (m *rcache) Add(key string, val []byte, expiration time.Duration) (err erro
 }
 
 // Set writes the given item, unconditionally.
-func (m *rcache) Set(key string, val []byte, expiration time.Duration) (err error) {
+func (m *rcache) Set(key string, val []byte, expiration time.Duration) error {
+	return m.SetCtx(context.Background(), key, val, expiration)
+}
+
+// SetCtx is Set which respects the context deadline.
+func (m *rcache) SetCtx(ctx context.Context, key string, val []byte, expiration time.Duration) (err error) {
+	defer m.observe("set", time.Now(), &err)
 
 	args := []string{key, string(val)}
 
@@ -159,7 +217,9 @@ func (m *rcache) Set(key string, val []byte, expiration time.Duration) (err erro
 		args = append(args, "EX", fmt.Sprintf("%d", int(expiration.Seconds())))
 	}
 
-	err = m.client.Do(radix.Cmd(nil, "SET", args...))
+	err = doCtx(ctx, func() error {
+		return m.client.Do(radix.Cmd(nil, "SET", args...))
+	})
 	if err != nil {
 		m.logError(fmt.Sprintf("%s %s %s", key, string(val), err.Error()))
 		return
@@ -170,11 +230,381 @@ func (m *rcache) Set(key string, val []byte, expiration time.Duration) (err erro
 
 // Delete deletes the item with the provided key.
 // return nil error if the item didn't already exist in the cache.
-func (m *rcache) Delete(key string) (err error) {
-	err = m.client.Do(radix.Cmd(nil, "DEL", key))
+func (m *rcache) Delete(key string) error {
+	return m.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is Delete which respects the context deadline.
+func (m *rcache) DeleteCtx(ctx context.Context, key string) (err error) {
+	defer m.observe("delete", time.Now(), &err)
+	err = doCtx(ctx, func() error {
+		return m.client.Do(radix.Cmd(nil, "DEL", key))
+	})
+	if err != nil {
+		m.logError(fmt.Sprintf("%s %s", key, err.Error()))
+		return
+	}
+	return
+}
+
+// setNX writes the item only if the key does not exist and reports whether it was stored
+func (m *rcache) setNX(key string, val []byte, expiration time.Duration) (stored bool, err error) {
+	args := []string{key, string(val), "NX"}
+	if expiration > 0 {
+		args = append(args, "PX", fmt.Sprintf("%d", expiration.Milliseconds()))
+	}
+
+	var rcv string
+	mn := radix.MaybeNil{Rcv: &rcv}
+	err = m.client.Do(radix.Cmd(&mn, "SET", args...))
+	if err != nil {
+		m.logError(fmt.Sprintf("%s %s", key, err.Error()))
+		return
+	}
+	return !mn.Nil, nil
+}
+
+var (
+	compareAndDeleteScript = radix.NewEvalScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	compareAndExpireScript = radix.NewEvalScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// compareAndDelete deletes the key only if it holds val
+func (m *rcache) compareAndDelete(key string, val []byte) (deleted bool, err error) {
+	var rcv int
+	err = m.client.Do(compareAndDeleteScript.Cmd(&rcv, key, string(val)))
 	if err != nil {
 		m.logError(fmt.Sprintf("%s %s", key, err.Error()))
 		return
 	}
+	return rcv == 1, nil
+}
+
+// compareAndExpire sets new expiration of the key only if it holds val
+func (m *rcache) compareAndExpire(key string, val []byte, expiration time.Duration) (extended bool, err error) {
+	var rcv int
+	err = m.client.Do(compareAndExpireScript.Cmd(&rcv, key, string(val), fmt.Sprintf("%d", expiration.Milliseconds())))
+	if err != nil {
+		m.logError(fmt.Sprintf("%s %s", key, err.Error()))
+		return
+	}
+	return rcv == 1, nil
+}
+
+// Script lua script for Scripter, loaded once by EVALSHA and falls back to EVAL
+type Script struct {
+	numKeys int
+	eval    radix.EvalScript
+}
+
+// NewScript create script taking numKeys keys followed by arguments.
+// In cluster topology all keys must belong to the same hash slot.
+func NewScript(numKeys int, src string) *Script {
+	return &Script{numKeys: numKeys, eval: radix.NewEvalScript(numKeys, src)}
+}
+
+// Eval runs the script atomically, rcv receives the reply
+func (m *rcache) Eval(rcv interface{}, script *Script, keys []string, args ...string) (err error) {
+	if len(keys) != script.numKeys {
+		return fmt.Errorf("script expects %d keys, got %d", script.numKeys, len(keys))
+	}
+	err = m.client.Do(script.eval.Cmd(rcv, append(append([]string{}, keys...), args...)...))
+	if err != nil {
+		m.logError(fmt.Sprintf("%v %s", keys, err.Error()))
+	}
 	return
 }
+
+// KEYS[1] counter, ARGV[1] delta, ARGV[2] expiration in milliseconds
+var incrScript = NewScript(1, `
+local count = redis.call("INCRBY", KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return count`)
+
+// Incr increments the counter by delta using INCRBY, a missing counter starts from 0
+func (m *rcache) Incr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return m.incrBy("incr", key, delta, expiration)
+}
+
+// Decr decrements the counter by delta, a missing counter starts from 0
+func (m *rcache) Decr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return m.incrBy("decr", key, -delta, expiration)
+}
+
+func (m *rcache) incrBy(operation, key string, delta int64, expiration time.Duration) (count int64, err error) {
+	defer m.observe(operation, time.Now(), &err)
+	err = m.Eval(&count, incrScript, []string{key}, strconv.FormatInt(delta, 10), strconv.FormatInt(expiration.Milliseconds(), 10))
+	return
+}
+
+// TTL returns remaining time to live using PTTL
+func (m *rcache) TTL(key string) (ttl time.Duration, err error) {
+	defer m.observe("ttl", time.Now(), &err)
+	var ms int64
+	err = m.client.Do(radix.Cmd(&ms, "PTTL", key))
+	if err != nil {
+		m.logError(fmt.Sprintf("%s %s", key, err.Error()))
+		return
+	}
+	return pttl(ms), nil
+}
+
+// pttl converts PTTL reply, 0 for missing key and NoExpiration for key without expiration
+func pttl(ms int64) time.Duration {
+	switch {
+	case ms == -2:
+		return 0
+	case ms == -1:
+		return NoExpiration
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// Touch resets the expiration using PEXPIRE, 0 expiration uses PERSIST
+func (m *rcache) Touch(key string, expiration time.Duration) (err error) {
+	defer m.observe("touch", time.Now(), &err)
+	if expiration > 0 {
+		err = m.client.Do(radix.Cmd(nil, "PEXPIRE", key, strconv.FormatInt(expiration.Milliseconds(), 10)))
+	} else {
+		err = m.client.Do(radix.Cmd(nil, "PERSIST", key))
+	}
+	if err != nil {
+		m.logError(fmt.Sprintf("%s %s", key, err.Error()))
+	}
+	return
+}
+
+// Exists reports whether the key exists
+func (m *rcache) Exists(key string) (exists bool, err error) {
+	defer m.observe("exists", time.Now(), &err)
+	var n int
+	err = m.client.Do(radix.Cmd(&n, "EXISTS", key))
+	if err != nil {
+		m.logError(fmt.Sprintf("%s %s", key, err.Error()))
+		return
+	}
+	return n > 0, nil
+}
+
+// slotGroups splits keys so every group can be sent in a single command,
+// in cluster topology keys of one group belong to the same hash slot
+func (m *rcache) slotGroups(keys []string) [][]string {
+	if m.topology != Cluster {
+		return [][]string{keys}
+	}
+
+	var groups [][]string
+	index := map[uint16]int{}
+	for _, key := range keys {
+		slot := radix.ClusterSlot([]byte(key))
+		i, ok := index[slot]
+		if !ok {
+			i = len(groups)
+			index[slot] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], key)
+	}
+	return groups
+}
+
+// GetMulti gets the items with the provided keys using MGET.
+// Keys which didn't exist in the cache are omitted from the result.
+func (m *rcache) GetMulti(keys []string) (items map[string][]byte, err error) {
+	defer func(start time.Time) {
+		observeGetMulti(m.metrics, "redis", "get_multi", start, len(keys), len(items), err)
+	}(time.Now())
+
+	return m.mget(keys, nil)
+}
+
+// getWithTTL is GetMulti which also returns the remaining time to live of every found item,
+// PTTL of the keys is pipelined with MGET so both are read in one round trip.
+func (m *rcache) getWithTTL(keys []string) (items map[string][]byte, ttls map[string]time.Duration, err error) {
+	defer func(start time.Time) {
+		observeGetMulti(m.metrics, "redis", "get_multi", start, len(keys), len(items), err)
+	}(time.Now())
+
+	ttls = make(map[string]time.Duration, len(keys))
+	items, err = m.mget(keys, ttls)
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// mget reads the keys using MGET, when ttls isn't nil PTTL of every found key is stored into it
+func (m *rcache) mget(keys []string, ttls map[string]time.Duration) (items map[string][]byte, err error) {
+	items = make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return
+	}
+
+	for _, group := range m.slotGroups(keys) {
+		var rcv [][]byte
+		ms := make([]int64, len(group))
+		if ttls == nil {
+			err = m.client.Do(radix.Cmd(&rcv, "MGET", group...))
+		} else {
+			cmds := make([]radix.CmdAction, 0, len(group)+1)
+			cmds = append(cmds, radix.Cmd(&rcv, "MGET", group...))
+			for i, key := range group {
+				cmds = append(cmds, radix.Cmd(&ms[i], "PTTL", key))
+			}
+			err = m.client.Do(radix.Pipeline(cmds...))
+		}
+		if err != nil {
+			m.logError(fmt.Sprintf("%v %s", group, err.Error()))
+			return nil, err
+		}
+		for i, val := range rcv {
+			if val == nil {
+				continue
+			}
+			items[group[i]] = val
+			if ttls != nil {
+				ttls[group[i]] = pttl(ms[i])
+			}
+		}
+	}
+
+	if err = untag(context.Background(), m, items); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// SetMulti writes the given items unconditionally using pipelined SET.
+func (m *rcache) SetMulti(items map[string][]byte, expiration time.Duration) (err error) {
+	defer m.observe("set_multi", time.Now(), &err)
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	for _, group := range m.slotGroups(keys) {
+		cmds := make([]radix.CmdAction, 0, len(group))
+		for _, key := range group {
+			args := []string{key, string(items[key])}
+			if expiration != 0 {
+				args = append(args, "EX", fmt.Sprintf("%d", int(expiration.Seconds())))
+			}
+			cmds = append(cmds, radix.Cmd(nil, "SET", args...))
+		}
+
+		err = m.client.Do(radix.Pipeline(cmds...))
+		if err != nil {
+			m.logError(fmt.Sprintf("%v %s", group, err.Error()))
+			return
+		}
+	}
+
+	return
+}
+
+// DeleteMulti deletes the items with the provided keys.
+// return nil error if the items didn't already exist in the cache.
+func (m *rcache) DeleteMulti(keys []string) (err error) {
+	defer m.observe("delete_multi", time.Now(), &err)
+	if len(keys) == 0 {
+		return
+	}
+
+	for _, group := range m.slotGroups(keys) {
+		err = m.client.Do(radix.Cmd(nil, "DEL", group...))
+		if err != nil {
+			m.logError(fmt.Sprintf("%v %s", group, err.Error()))
+			return
+		}
+	}
+
+	return
+}
+
+// SetWithTags writes the item, Get treats it as missing once any of its tags is invalidated
+func (m *rcache) SetWithTags(key string, val []byte, expiration time.Duration, tags ...string) error {
+	return setWithTags(m, key, val, expiration, tags)
+}
+
+// InvalidateTag invalidates every item written with the tag in O(1)
+func (m *rcache) InvalidateTag(tag string) error {
+	return invalidateTag(m, tag)
+}
+
+var compareAndSwapScript = radix.NewEvalScript(1, `
+local cur = redis.call("GET", KEYS[1])
+if ARGV[1] == "1" then
+	if cur ~= ARGV[2] then
+		return 0
+	end
+elseif cur then
+	return 0
+end
+if tonumber(ARGV[4]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[3], "PX", ARGV[4])
+else
+	redis.call("SET", KEYS[1], ARGV[3])
+end
+return 1`)
+
+// GetWithToken gets the item and its token for CompareAndSwap.
+// Return nil byte if the item didn't already exist in the cache.
+func (m *rcache) GetWithToken(key string) (val []byte, token CASToken, err error) {
+	defer m.observe("get_with_token", time.Now(), &err)
+	if err = m.do(key, &token.val, "GET", key); err != nil {
+		return nil, CASToken{}, err
+	}
+	val, err = untagOne(context.Background(), m, key, token.val)
+	return
+}
+
+// CompareAndSwap writes val only if the item still holds the value read by GetWithToken,
+// ErrCASConflict otherwise. The value is compared, not a version, so a value changed and
+// changed back is not a conflict.
+func (m *rcache) CompareAndSwap(key string, val []byte, expiration time.Duration, token CASToken) (err error) {
+	defer m.observe("compare_and_swap", time.Now(), &err)
+
+	existed := "0"
+	if token.val != nil {
+		existed = "1"
+	}
+	var rcv int
+	err = m.client.Do(compareAndSwapScript.Cmd(&rcv, key, existed, string(token.val), string(val),
+		strconv.FormatInt(expiration.Milliseconds(), 10)))
+	if err != nil {
+		m.logError(fmt.Sprintf("%s %s", key, err.Error()))
+		return
+	}
+	if rcv == 0 {
+		return ErrCASConflict
+	}
+	return
+}
+
+// primaryAddr returns address of current primary, following sentinel failover
+func (m *rcache) primaryAddr() string {
+	if m.sentinelConn != nil {
+		addr, _ := m.sentinelConn.Addrs()
+		return addr
+	}
+	return m.servers[0]
+}
+
+func (m *rcache) cacheLogger() logger.Logger {
+	return m.logger
+}
@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// ParseURL parses redis URL into Config:
+//
+//	redis://[[user]:pass@]host:port[/db]            standalone, several comma separated hosts for cluster
+//	rediss://[[user]:pass@]host:port[/db]           standalone or cluster over TLS
+//	redis-sentinel://[[user]:pass@]host:port,host:port/primary[/db]
+//
+// Query parameters dial_timeout, read_timeout, write_timeout (e.g. 500ms), pool_size
+// and for sentinel tls=true are understood.
+func ParseURL(rawurl string) (cfg Config, err error) {
+	cfg, _, err = parseURL(rawurl)
+	return
+}
+
+// urlFields fields given by a redis URL, others are left to the Config
+type urlFields struct {
+	db   bool
+	auth bool
+}
+
+func parseURL(rawurl string) (cfg Config, set urlFields, err error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return
+	}
+
+	hosts := strings.Split(u.Host, ",")
+	if u.Host == "" {
+		return cfg, set, errors.New("redis url without host")
+	}
+	path := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch u.Scheme {
+	case "redis", "rediss":
+		cfg.Servers = hosts
+		if len(hosts) > 1 {
+			cfg.Topology = Cluster
+		}
+		cfg.TLS.Enabled = u.Scheme == "rediss"
+	case "redis-sentinel":
+		if path[0] == "" {
+			return cfg, set, errors.New("redis-sentinel url without primary name")
+		}
+		cfg.Topology = Sentinel
+		cfg.Sentinel = SentinelConfig{PrimaryName: path[0], Addrs: hosts}
+		path = path[1:]
+	default:
+		return cfg, set, fmt.Errorf("unknown redis url scheme %q", u.Scheme)
+	}
+
+	if len(path) > 0 && path[0] != "" {
+		set.db = true
+		if cfg.DB, err = strconv.Atoi(path[0]); err != nil {
+			return cfg, set, fmt.Errorf("invalid redis db %q", path[0])
+		}
+	}
+
+	if u.User != nil {
+		set.auth = true
+		cfg.Username = u.User.Username()
+		cfg.AuthPass, _ = u.User.Password()
+		//redis://:pass@host is password only, redis://pass@host too
+		if _, ok := u.User.Password(); !ok {
+			cfg.Username, cfg.AuthPass = "", cfg.Username
+		}
+	}
+
+	q := u.Query()
+	for name, d := range map[string]*time.Duration{
+		"dial_timeout":  &cfg.DialTimeout,
+		"read_timeout":  &cfg.ReadTimeout,
+		"write_timeout": &cfg.WriteTimeout,
+	} {
+		if v := q.Get(name); v != "" {
+			if *d, err = time.ParseDuration(v); err != nil {
+				return cfg, set, fmt.Errorf("invalid redis %s %q", name, v)
+			}
+		}
+	}
+	if v := q.Get("pool_size"); v != "" {
+		if cfg.PoolSize, err = strconv.Atoi(v); err != nil {
+			return cfg, set, fmt.Errorf("invalid redis pool_size %q", v)
+		}
+	}
+	if q.Get("tls") == "true" {
+		cfg.TLS.Enabled = true
+	}
+	return cfg, set, nil
+}
+
+// withURL returns cfg with the fields set by cfg.URL replaced
+func (cfg Config) withURL() (Config, error) {
+	if cfg.URL == "" {
+		return cfg, nil
+	}
+	u, set, err := parseURL(cfg.URL)
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.Topology, cfg.Servers, cfg.Sentinel = u.Topology, u.Servers, u.Sentinel
+	if set.db {
+		cfg.DB = u.DB
+	}
+	if set.auth {
+		cfg.Username, cfg.AuthPass = u.Username, u.AuthPass
+	}
+	if u.TLS.Enabled {
+		cfg.TLS.Enabled = true
+	}
+	for _, d := range []struct{ dst, src *time.Duration }{
+		{&cfg.DialTimeout, &u.DialTimeout},
+		{&cfg.ReadTimeout, &u.ReadTimeout},
+		{&cfg.WriteTimeout, &u.WriteTimeout},
+	} {
+		if *d.src != 0 {
+			*d.dst = *d.src
+		}
+	}
+	if u.PoolSize != 0 {
+		cfg.PoolSize = u.PoolSize
+	}
+	return cfg, nil
+}
+
+// tlsConfig builds tls config of the TLS settings
+func (t TLSConfig) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// dialOpts returns timeouts and TLS options of the config
+func (cfg Config) dialOpts() (opts []radix.DialOpt, err error) {
+	if cfg.Timeout != 0 {
+		opts = append(opts, radix.DialTimeout(cfg.Timeout))
+	}
+	if cfg.DialTimeout != 0 {
+		opts = append(opts, radix.DialConnectTimeout(cfg.DialTimeout))
+	}
+	if cfg.ReadTimeout != 0 {
+		opts = append(opts, radix.DialReadTimeout(cfg.ReadTimeout))
+	}
+	if cfg.WriteTimeout != 0 {
+		opts = append(opts, radix.DialWriteTimeout(cfg.WriteTimeout))
+	}
+	if cfg.TLS.Enabled {
+		conf, err := cfg.TLS.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, radix.DialUseTLS(conf))
+	}
+	return
+}
+
+// connFunc dials with the config and authenticates, radix can't send ACL username
+// so AUTH and SELECT are sent here
+func (cfg Config) connFunc(opts []radix.DialOpt) radix.ConnFunc {
+	return func(network, addr string) (radix.Conn, error) {
+		conn, err := radix.Dial(network, addr, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		var cmds []radix.CmdAction
+		switch {
+		case cfg.Username != "":
+			cmds = append(cmds, radix.Cmd(nil, "AUTH", cfg.Username, cfg.AuthPass))
+		case cfg.AuthPass != "":
+			cmds = append(cmds, radix.Cmd(nil, "AUTH", cfg.AuthPass))
+		}
+		if cfg.DB != 0 {
+			cmds = append(cmds, radix.Cmd(nil, "SELECT", strconv.Itoa(cfg.DB)))
+		}
+		for _, cmd := range cmds {
+			if err = conn.Do(cmd); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		return conn, nil
+	}
+}
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// do runs the command and logs the error with the key
+func (m *rcache) do(key string, rcv interface{}, cmd string, args ...string) (err error) {
+	err = m.client.Do(radix.Cmd(rcv, cmd, args...))
+	if err != nil {
+		m.logError(fmt.Sprintf("%s %s %s", cmd, key, err.Error()))
+	}
+	return
+}
+
+// HGet the field of the hash.
+// Return nil byte if the field didn't exist.
+func (m *rcache) HGet(key, field string) (rcv []byte, err error) {
+	err = m.do(key, &rcv, "HGET", key, field)
+	return
+}
+
+// HSet writes the fields of the hash
+func (m *rcache) HSet(key string, fields map[string][]byte) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	args := make([]string, 0, 1+len(fields)*2)
+	args = append(args, key)
+	for field, val := range fields {
+		args = append(args, field, string(val))
+	}
+	return m.do(key, nil, "HSET", args...)
+}
+
+// HGetAll gets every field of the hash, empty map if the hash didn't exist
+func (m *rcache) HGetAll(key string) (fields map[string][]byte, err error) {
+	fields = map[string][]byte{}
+	err = m.do(key, &fields, "HGETALL", key)
+	return
+}
+
+// HDel deletes the fields of the hash
+func (m *rcache) HDel(key string, fields ...string) error {
+	return m.do(key, nil, "HDEL", append([]string{key}, fields...)...)
+}
+
+func (m *rcache) push(cmd, key string, vals [][]byte) (n int64, err error) {
+	args := make([]string, 0, 1+len(vals))
+	args = append(args, key)
+	for _, val := range vals {
+		args = append(args, string(val))
+	}
+	err = m.do(key, &n, cmd, args...)
+	return
+}
+
+// LPush prepends the values to the list and returns the list length
+func (m *rcache) LPush(key string, vals ...[]byte) (int64, error) {
+	return m.push("LPUSH", key, vals)
+}
+
+// RPush appends the values to the list and returns the list length
+func (m *rcache) RPush(key string, vals ...[]byte) (int64, error) {
+	return m.push("RPUSH", key, vals)
+}
+
+// LPop removes and returns the first element.
+// Return nil byte if the list is empty.
+func (m *rcache) LPop(key string) (rcv []byte, err error) {
+	err = m.do(key, &rcv, "LPOP", key)
+	return
+}
+
+// RPop removes and returns the last element.
+// Return nil byte if the list is empty.
+func (m *rcache) RPop(key string) (rcv []byte, err error) {
+	err = m.do(key, &rcv, "RPOP", key)
+	return
+}
+
+// LRange returns the elements between start and stop inclusive, negative index counts from the end
+func (m *rcache) LRange(key string, start, stop int) (vals [][]byte, err error) {
+	err = m.do(key, &vals, "LRANGE", key, strconv.Itoa(start), strconv.Itoa(stop))
+	return
+}
+
+// LLen returns the list length
+func (m *rcache) LLen(key string) (n int64, err error) {
+	err = m.do(key, &n, "LLEN", key)
+	return
+}
+
+// SAdd adds the members to the set and returns the number of new members
+func (m *rcache) SAdd(key string, members ...string) (n int64, err error) {
+	err = m.do(key, &n, "SADD", append([]string{key}, members...)...)
+	return
+}
+
+// SRem removes the members from the set and returns the number of removed members
+func (m *rcache) SRem(key string, members ...string) (n int64, err error) {
+	err = m.do(key, &n, "SREM", append([]string{key}, members...)...)
+	return
+}
+
+// SMembers returns every member of the set
+func (m *rcache) SMembers(key string) (members []string, err error) {
+	err = m.do(key, &members, "SMEMBERS", key)
+	return
+}
+
+// SIsMember reports whether member belongs to the set
+func (m *rcache) SIsMember(key, member string) (bool, error) {
+	var n int
+	err := m.do(key, &n, "SISMEMBER", key, member)
+	return n == 1, err
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// parseZMembers parses member score pairs replied by WITHSCORES
+func parseZMembers(reply []string) ([]ZMember, error) {
+	members := make([]ZMember, 0, len(reply)/2)
+	for i := 0; i+1 < len(reply); i += 2 {
+		score, err := strconv.ParseFloat(reply[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, ZMember{Member: reply[i], Score: score})
+	}
+	return members, nil
+}
+
+// ZAdd adds or updates the members of the sorted set and returns the number of new members
+func (m *rcache) ZAdd(key string, members ...ZMember) (n int64, err error) {
+	args := make([]string, 0, 1+len(members)*2)
+	args = append(args, key)
+	for _, member := range members {
+		args = append(args, formatScore(member.Score), member.Member)
+	}
+	err = m.do(key, &n, "ZADD", args...)
+	return
+}
+
+// ZRem removes the members from the sorted set and returns the number of removed members
+func (m *rcache) ZRem(key string, members ...string) (n int64, err error) {
+	err = m.do(key, &n, "ZREM", append([]string{key}, members...)...)
+	return
+}
+
+// ZIncrBy increments the member score and returns the new score
+func (m *rcache) ZIncrBy(key string, delta float64, member string) (score float64, err error) {
+	var rcv string
+	if err = m.do(key, &rcv, "ZINCRBY", key, formatScore(delta), member); err != nil {
+		return
+	}
+	return strconv.ParseFloat(rcv, 64)
+}
+
+// ZScore returns the member score, exists is false if the member didn't exist
+func (m *rcache) ZScore(key, member string) (score float64, exists bool, err error) {
+	var rcv string
+	mn := radix.MaybeNil{Rcv: &rcv}
+	if err = m.do(key, &mn, "ZSCORE", key, member); err != nil || mn.Nil {
+		return
+	}
+	score, err = strconv.ParseFloat(rcv, 64)
+	return score, err == nil, err
+}
+
+// ZRangeByScore returns members with score between min and max ordered by score,
+// count below 1 returns every member after offset
+func (m *rcache) ZRangeByScore(key, min, max string, offset, count int) ([]ZMember, error) {
+	if count < 1 {
+		count = -1
+	}
+
+	var reply []string
+	err := m.do(key, &reply, "ZRANGEBYSCORE", key, min, max, "WITHSCORES", "LIMIT", strconv.Itoa(offset), strconv.Itoa(count))
+	if err != nil {
+		return nil, err
+	}
+	return parseZMembers(reply)
+}
+
+// ZRevRangeWithScores returns members between start and stop rank ordered by descending score,
+// e.g. 0, 9 is the top ten of a leaderboard
+func (m *rcache) ZRevRangeWithScores(key string, start, stop int) ([]ZMember, error) {
+	var reply []string
+	err := m.do(key, &reply, "ZREVRANGE", key, strconv.Itoa(start), strconv.Itoa(stop), "WITHSCORES")
+	if err != nil {
+		return nil, err
+	}
+	return parseZMembers(reply)
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+// newRecordingRedis replies canned values and records the received commands
+func newRecordingRedis(reply func(args []string) interface{}) (*rcache, *[][]string) {
+	var cmds [][]string
+	conn := radix.Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		cmds = append(cmds, args)
+		return reply(args)
+	})
+	return &rcache{client: conn}, &cmds
+}
+
+func TestRedisHashes(t *testing.T) {
+	x, cmds := newRecordingRedis(func(args []string) interface{} {
+		switch args[0] {
+		case "HGET":
+			return nil
+		case "HGETALL":
+			return []string{"name", "lorem", "age", "17"}
+		}
+		return 1
+	})
+
+	b, err := x.HGet("user:1", "missing")
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	assert.Nil(t, x.HSet("user:1", map[string][]byte{"name": []byte("lorem")}))
+	assert.Equal(t, []string{"HSET", "user:1", "name", "lorem"}, (*cmds)[1])
+
+	fields, err := x.HGetAll("user:1")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"name": []byte("lorem"), "age": []byte("17")}, fields)
+
+	assert.Nil(t, x.HDel("user:1", "name", "age"))
+	assert.Equal(t, []string{"HDEL", "user:1", "name", "age"}, (*cmds)[3])
+}
+
+func TestRedisLists(t *testing.T) {
+	x, cmds := newRecordingRedis(func(args []string) interface{} {
+		switch args[0] {
+		case "LPUSH":
+			return 2
+		case "RPOP":
+			return nil
+		case "LRANGE":
+			return []string{"b", "a"}
+		}
+		return 0
+	})
+
+	n, err := x.LPush("queue", []byte("a"), []byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, []string{"LPUSH", "queue", "a", "b"}, (*cmds)[0])
+
+	b, err := x.RPop("queue")
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	vals, err := x.LRange("queue", 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("a")}, vals)
+	assert.Equal(t, []string{"LRANGE", "queue", "0", "-1"}, (*cmds)[2])
+}
+
+func TestRedisSets(t *testing.T) {
+	x, _ := newRecordingRedis(func(args []string) interface{} {
+		switch args[0] {
+		case "SMEMBERS":
+			return []string{"a", "b"}
+		case "SISMEMBER":
+			return 1
+		}
+		return 2
+	})
+
+	n, err := x.SAdd("tags", "a", "b")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+
+	members, _ := x.SMembers("tags")
+	assert.Equal(t, []string{"a", "b"}, members)
+
+	ok, _ := x.SIsMember("tags", "a")
+	assert.True(t, ok)
+}
+
+func TestRedisSortedSets(t *testing.T) {
+	x, cmds := newRecordingRedis(func(args []string) interface{} {
+		switch args[0] {
+		case "ZINCRBY":
+			return "12.5"
+		case "ZSCORE":
+			return nil
+		case "ZRANGEBYSCORE", "ZREVRANGE":
+			return []string{"alice", "10", "bob", "7.5"}
+		}
+		return 1
+	})
+
+	n, err := x.ZAdd("board", ZMember{Member: "alice", Score: 10}, ZMember{Member: "bob", Score: 7.5})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, []string{"ZADD", "board", "10", "alice", "7.5", "bob"}, (*cmds)[0])
+
+	score, err := x.ZIncrBy("board", 2.5, "alice")
+	assert.Nil(t, err)
+	assert.Equal(t, 12.5, score)
+
+	_, exists, err := x.ZScore("board", "carol")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	members, err := x.ZRangeByScore("board", "-inf", "+inf", 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []ZMember{{Member: "alice", Score: 10}, {Member: "bob", Score: 7.5}}, members)
+	assert.Equal(t, []string{"ZRANGEBYSCORE", "board", "-inf", "+inf", "WITHSCORES", "LIMIT", "0", "-1"}, (*cmds)[3])
+
+	members, _ = x.ZRevRangeWithScores("board", 0, 9)
+	assert.Len(t, members, 2)
+}
@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// failoverCheckInterval how often subscriptions check sentinel for a new primary
+var failoverCheckInterval = time.Second
+
+// Publish sends payload to the pub/sub channel
+func (m *rcache) Publish(channel string, payload []byte) error {
+	return m.do(channel, nil, "PUBLISH", channel, string(payload))
+}
+
+// newPubSub opens subscription connection to the current primary which resubscribes after reconnect
+func (m *rcache) newPubSub(msgCh chan<- radix.PubSubMessage, channels []string) (radix.PubSubConn, error) {
+	ps, err := radix.PersistentPubSubWithOpts("tcp", "", radix.PersistentPubSubConnFunc(func(network, _ string) (radix.Conn, error) {
+		return m.connFunc(network, m.primaryAddr())
+	}))
+	if err != nil {
+		return nil, err
+	}
+	if err = ps.Subscribe(msgCh, channels...); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// Subscribe calls handler for every message of the channels until ctx is done.
+// Handler is called from a single goroutine in publish order.
+func (m *rcache) Subscribe(ctx context.Context, channels []string, handler func(channel string, payload []byte)) error {
+	msgCh := make(chan radix.PubSubMessage)
+	ps, err := m.newPubSub(msgCh, channels)
+	if err != nil {
+		m.logError(fmt.Sprintf("%v %s", channels, err.Error()))
+		return err
+	}
+
+	// radix requires msgCh to be read until Close returns
+	closePubSub := func(ps radix.PubSubConn, deliver bool) {
+		done := make(chan struct{})
+		go func() {
+			ps.Close()
+			close(done)
+		}()
+		for {
+			select {
+			case <-done:
+				return
+			case msg := <-msgCh:
+				if deliver {
+					handler(msg.Channel, msg.Message)
+				}
+			}
+		}
+	}
+
+	go func() {
+		var failover <-chan time.Time
+		primary := m.primaryAddr()
+		if m.sentinelConn != nil {
+			ticker := time.NewTicker(failoverCheckInterval)
+			defer ticker.Stop()
+			failover = ticker.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				closePubSub(ps, false)
+				return
+			case msg := <-msgCh:
+				handler(msg.Channel, msg.Message)
+			case <-failover:
+				addr := m.primaryAddr()
+				if addr == primary {
+					continue
+				}
+				// sentinel promoted another primary, move the subscription over
+				next, err := m.newPubSub(msgCh, channels)
+				if err != nil {
+					m.logError(fmt.Sprintf("%v %s", channels, err.Error()))
+					continue
+				}
+				closePubSub(ps, true)
+				ps, primary = next, addr
+			}
+		}
+	}()
+
+	return nil
+}
+
+// XAdd appends the message and returns its id
+func (m *rcache) XAdd(stream string, maxLen int64, fields map[string]string) (id string, err error) {
+	args := []string{stream}
+	if maxLen > 0 {
+		args = append(args, "MAXLEN", "~", strconv.FormatInt(maxLen, 10))
+	}
+	args = append(args, "*")
+	for field, val := range fields {
+		args = append(args, field, val)
+	}
+	err = m.do(stream, &id, "XADD", args...)
+	return
+}
+
+// XGroupCreate creates the consumer group, an existing group is not an error
+func (m *rcache) XGroupCreate(stream, group, id string) error {
+	err := m.client.Do(radix.Cmd(nil, "XGROUP", "CREATE", stream, group, id, "MKSTREAM"))
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		m.logError(fmt.Sprintf("XGROUP %s %s", stream, err.Error()))
+		return err
+	}
+	return nil
+}
+
+// streamReply one stream of XREADGROUP reply
+type streamReply struct {
+	stream  string
+	entries []radix.StreamEntry
+}
+
+func (s *streamReply) UnmarshalRESP(br *bufio.Reader) error {
+	var ah resp2.ArrayHeader
+	if err := ah.UnmarshalRESP(br); err != nil {
+		return err
+	}
+	if ah.N != 2 {
+		return errors.New("invalid xreadgroup reply")
+	}
+
+	var name resp2.BulkString
+	if err := name.UnmarshalRESP(br); err != nil {
+		return err
+	}
+	s.stream = name.S
+
+	return resp2.Any{I: &s.entries}.UnmarshalRESP(br)
+}
+
+func toStreamMessages(entries []radix.StreamEntry) []StreamMessage {
+	msgs := make([]StreamMessage, 0, len(entries))
+	for _, entry := range entries {
+		msgs = append(msgs, StreamMessage{ID: entry.ID.String(), Fields: entry.Fields})
+	}
+	return msgs
+}
+
+// XReadGroup reads new messages for consumer of the group
+func (m *rcache) XReadGroup(ctx context.Context, stream, group, consumer string, count int, block time.Duration) ([]StreamMessage, error) {
+	args := []string{"GROUP", group, consumer}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+	if block > 0 {
+		args = append(args, "BLOCK", strconv.FormatInt(block.Milliseconds(), 10))
+	}
+	args = append(args, "STREAMS", stream, ">")
+
+	var replies []streamReply
+	err := doCtx(ctx, func() error {
+		return m.client.Do(radix.Cmd(&radix.MaybeNil{Rcv: &replies}, "XREADGROUP", args...))
+	})
+	if err != nil {
+		m.logError(fmt.Sprintf("XREADGROUP %s %s", stream, err.Error()))
+		return nil, err
+	}
+
+	msgs := []StreamMessage{}
+	for _, reply := range replies {
+		msgs = append(msgs, toStreamMessages(reply.entries)...)
+	}
+	return msgs, nil
+}
+
+// XAck acknowledges processed messages and returns the number acknowledged
+func (m *rcache) XAck(stream, group string, ids ...string) (n int64, err error) {
+	err = m.do(stream, &n, "XACK", append([]string{stream, group}, ids...)...)
+	return
+}
+
+// pendingReply one entry of extended XPENDING reply
+type pendingReply struct {
+	PendingMessage
+}
+
+func (p *pendingReply) UnmarshalRESP(br *bufio.Reader) error {
+	var ah resp2.ArrayHeader
+	if err := ah.UnmarshalRESP(br); err != nil {
+		return err
+	}
+	if ah.N != 4 {
+		return errors.New("invalid xpending reply")
+	}
+
+	var id, consumer resp2.BulkString
+	var idle, deliveries resp2.Int
+	for _, u := range []interface{ UnmarshalRESP(*bufio.Reader) error }{&id, &consumer, &idle, &deliveries} {
+		if err := u.UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+
+	p.PendingMessage = PendingMessage{
+		ID:         id.S,
+		Consumer:   consumer.S,
+		Idle:       time.Duration(idle.I) * time.Millisecond,
+		Deliveries: deliveries.I,
+	}
+	return nil
+}
+
+// XPending lists up to count pending messages of the group
+func (m *rcache) XPending(stream, group string, count int) ([]PendingMessage, error) {
+	var replies []pendingReply
+	err := m.do(stream, &replies, "XPENDING", stream, group, "-", "+", strconv.Itoa(count))
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]PendingMessage, 0, len(replies))
+	for _, reply := range replies {
+		pending = append(pending, reply.PendingMessage)
+	}
+	return pending, nil
+}
+
+// XClaim takes over pending messages idle for at least minIdle
+func (m *rcache) XClaim(stream, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamMessage, error) {
+	if len(ids) == 0 {
+		return []StreamMessage{}, nil
+	}
+
+	args := append([]string{stream, group, consumer, strconv.FormatInt(minIdle.Milliseconds(), 10)}, ids...)
+	var entries []radix.StreamEntry
+	if err := m.do(stream, &entries, "XCLAIM", args...); err != nil {
+		return nil, err
+	}
+	return toStreamMessages(entries), nil
+}
+
+// XClaimStuck claims up to count pending messages of the group idle for at least minIdle,
+// e.g. messages of a consumer which crashed before acknowledging them
+func (m *rcache) XClaimStuck(stream, group, consumer string, minIdle time.Duration, count int) ([]StreamMessage, error) {
+	pending, err := m.XPending(stream, group, count)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, p := range pending {
+		if p.Idle >= minIdle {
+			ids = append(ids, p.ID)
+		}
+	}
+	return m.XClaim(stream, group, consumer, minIdle, ids...)
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisSubscribe(t *testing.T) {
+	conn, pubCh := radix.PubSubStub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		return nil
+	})
+	x := &rcache{
+		servers: []string{"127.0.0.1:6379"},
+		connFunc: func(network, addr string) (radix.Conn, error) {
+			return conn, nil
+		},
+	}
+
+	received := make(chan string, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	err := x.Subscribe(ctx, []string{"events"}, func(channel string, payload []byte) {
+		received <- channel + ":" + string(payload)
+	})
+	assert.Nil(t, err)
+
+	pubCh <- radix.PubSubMessage{Type: "message", Channel: "events", Message: []byte("hello")}
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "events:hello", msg)
+	case <-time.After(time.Second):
+		t.Error("message is not delivered")
+	}
+	cancel()
+}
+
+func TestRedisStreams(t *testing.T) {
+	x, cmds := newRecordingRedis(func(args []string) interface{} {
+		switch args[0] {
+		case "XADD":
+			return "1-0"
+		case "XGROUP":
+			return resp2.Error{E: errors.New("BUSYGROUP Consumer Group name already exists")}
+		case "XREADGROUP":
+			return []interface{}{
+				[]interface{}{"orders", []interface{}{
+					[]interface{}{"1-0", []string{"id", "42"}},
+				}},
+			}
+		case "XPENDING":
+			return []interface{}{
+				[]interface{}{"1-0", "worker-1", 90000, 3},
+				[]interface{}{"2-0", "worker-1", 10, 1},
+			}
+		case "XCLAIM":
+			return []interface{}{
+				[]interface{}{"1-0", []string{"id", "42"}},
+			}
+		}
+		return 1
+	})
+
+	id, err := x.XAdd("orders", 1000, map[string]string{"id": "42"})
+	assert.Nil(t, err)
+	assert.Equal(t, "1-0", id)
+	assert.Equal(t, []string{"XADD", "orders", "MAXLEN", "~", "1000", "*", "id", "42"}, (*cmds)[0])
+
+	assert.Nil(t, x.XGroupCreate("orders", "billing", "$"))
+
+	msgs, err := x.XReadGroup(context.Background(), "orders", "billing", "worker-2", 10, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, []StreamMessage{{ID: "1-0", Fields: map[string]string{"id": "42"}}}, msgs)
+	assert.Equal(t, []string{"XREADGROUP", "GROUP", "billing", "worker-2", "COUNT", "10", "BLOCK", "1000", "STREAMS", "orders", ">"}, (*cmds)[2])
+
+	pending, err := x.XPending("orders", "billing", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, PendingMessage{ID: "1-0", Consumer: "worker-1", Idle: 90 * time.Second, Deliveries: 3}, pending[0])
+
+	msgs, err = x.XClaimStuck("orders", "billing", "worker-2", time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, []string{"XCLAIM", "orders", "billing", "worker-2", "60000", "1-0"}, (*cmds)[len(*cmds)-1])
+
+	n, err := x.XAck("orders", "billing", "1-0")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestRedisReadGroupTimeout(t *testing.T) {
+	x, _ := newRecordingRedis(func(args []string) interface{} {
+		return nil
+	})
+
+	msgs, err := x.XReadGroup(context.Background(), "orders", "billing", "worker-2", 10, time.Second)
+	assert.Nil(t, err)
+	assert.Empty(t, msgs)
+}
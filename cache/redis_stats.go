@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// primaries returns clients of every primary, cluster has one per shard
+func (m *rcache) primaries() (map[string]radix.Client, error) {
+	switch c := m.client.(type) {
+	case *radix.Cluster:
+		clients := map[string]radix.Client{}
+		for _, node := range c.Topo().Primaries() {
+			client, err := c.Client(node.Addr)
+			if err != nil {
+				return nil, err
+			}
+			clients[node.Addr] = client
+		}
+		return clients, nil
+	case *radix.Sentinel:
+		addr, _ := c.Addrs()
+		client, err := c.Client(addr)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]radix.Client{addr: client}, nil
+	}
+	return map[string]radix.Client{m.primaryAddr(): m.client}, nil
+}
+
+// Ping checks every primary responds, e.g. for readiness probe
+func (m *rcache) Ping(ctx context.Context) (err error) {
+	defer m.observe("ping", time.Now(), &err)
+
+	clients, err := m.primaries()
+	if err != nil {
+		m.logError(fmt.Sprintf("PING %s", err.Error()))
+		return err
+	}
+	for addr, client := range clients {
+		err = doCtx(ctx, func() error {
+			return client.Do(radix.Cmd(nil, "PING"))
+		})
+		if err != nil {
+			m.logError(fmt.Sprintf("PING %s %s", addr, err.Error()))
+			return fmt.Errorf("ping %s: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+// Close closes every pooled connection, the client is unusable afterwards.
+// Subscriptions are closed by cancelling their context.
+func (m *rcache) Close() error {
+	return m.client.Close()
+}
+
+// Stats reports topology and pool usage
+func (m *rcache) Stats() RedisStats {
+	stats := RedisStats{Topology: m.topology}
+
+	switch c := m.client.(type) {
+	case *radix.Cluster:
+		for _, node := range c.Topo() {
+			stats.Nodes = append(stats.Nodes, ClusterNode{
+				Addr:        node.Addr,
+				ID:          node.ID,
+				PrimaryAddr: node.SecondaryOfAddr,
+				Slots:       node.Slots,
+			})
+			if node.SecondaryOfAddr != "" {
+				stats.Replicas = append(stats.Replicas, node.Addr)
+			}
+		}
+	case *radix.Sentinel:
+		stats.Primary, stats.Replicas = c.Addrs()
+		stats.Sentinels = c.SentinelAddrs()
+		sort.Strings(stats.Replicas)
+		sort.Strings(stats.Sentinels)
+	default:
+		stats.Primary = m.primaryAddr()
+	}
+
+	clients, err := m.primaries()
+	if err != nil {
+		m.logError(fmt.Sprintf("Stats %s", err.Error()))
+		return stats
+	}
+	for addr, client := range clients {
+		if pool, ok := client.(*radix.Pool); ok {
+			stats.Pools = append(stats.Pools, PoolStats{Addr: addr, Size: m.poolSize, Idle: pool.NumAvailConns()})
+		}
+	}
+	sort.Slice(stats.Pools, func(i, j int) bool { return stats.Pools[i].Addr < stats.Pools[j].Addr })
+	return stats
+}
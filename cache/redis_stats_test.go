@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStubPool(t *testing.T, size int, reply func(args []string) interface{}) *radix.Pool {
+	pool, err := radix.NewPool("tcp", "127.0.0.1:6379", size, radix.PoolConnFunc(func(network, addr string) (radix.Conn, error) {
+		return radix.Stub(network, addr, reply), nil
+	}))
+	assert.Nil(t, err)
+	return pool
+}
+
+func TestRedisPingStats(t *testing.T) {
+	var pinged int
+	pool := newStubPool(t, 3, func(args []string) interface{} {
+		if args[0] == "PING" {
+			pinged++
+		}
+		return "PONG"
+	})
+	x := &rcache{client: pool, servers: []string{"127.0.0.1:6379"}, poolSize: 3}
+
+	assert.Nil(t, x.Ping(context.Background()))
+	assert.Equal(t, 1, pinged)
+
+	stats := x.Stats()
+	assert.Equal(t, Standalone, stats.Topology)
+	assert.Equal(t, "127.0.0.1:6379", stats.Primary)
+	assert.Equal(t, []PoolStats{{Addr: "127.0.0.1:6379", Size: 3, Idle: 3}}, stats.Pools)
+
+	assert.Nil(t, x.Close())
+	assert.NotNil(t, x.Ping(context.Background()))
+}
+
+func TestRedisPingContext(t *testing.T) {
+	x, _ := newRecordingRedis(func(args []string) interface{} {
+		time.Sleep(100 * time.Millisecond)
+		return "PONG"
+	})
+	x.servers = []string{"127.0.0.1:6379"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, errors.Is(x.Ping(ctx), context.Canceled))
+}
@@ -5,7 +5,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/armiariyan/bepkg/cache/internal/redisstub"
 	"github.com/armiariyan/bepkg/logger"
+	"github.com/mediocregopher/radix/v3"
+	"github.com/stretchr/testify/assert"
 )
 
 var mockConf = Config{
@@ -31,12 +34,18 @@ func doTaskEvery(d time.Duration, f func(time.Time) bool) {
 	}
 }
 
+func newStdoutLogger(t *testing.T) logger.Logger {
+	l, err := logger.New(logger.Options{Stdout: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
 func TestRedisSet(t *testing.T) {
 
 	x := redistestConn
-	x.SetLogger(logger.New(logger.Options{
-		Stdout: true,
-	}))
+	x.SetLogger(newStdoutLogger(t))
 	err := x.Add("test", []byte("ini lagi"), 1*time.Hour)
 	fmt.Println("Set")
 	fmt.Println(err)
@@ -45,9 +54,7 @@ func TestRedisSet(t *testing.T) {
 func TestRedisAdd(t *testing.T) {
 	x := redistestConn
 
-	x.SetLogger(logger.New(logger.Options{
-		Stdout: true,
-	}))
+	x.SetLogger(newStdoutLogger(t))
 	err := x.Add("test", []byte("ini isi test"), 1*time.Hour)
 
 	fmt.Println("Add")
@@ -57,9 +64,7 @@ func TestRedisAdd(t *testing.T) {
 func TestRedisGet(t *testing.T) {
 
 	x := redistestConn
-	x.SetLogger(logger.New(logger.Options{
-		Stdout: true,
-	}))
+	x.SetLogger(newStdoutLogger(t))
 	b, err := x.Get("test")
 
 	fmt.Println("Get")
@@ -70,9 +75,7 @@ func TestRedisGet(t *testing.T) {
 func TestRedisDelete(t *testing.T) {
 
 	x := redistestConn
-	x.SetLogger(logger.New(logger.Options{
-		Stdout: true,
-	}))
+	x.SetLogger(newStdoutLogger(t))
 	err := x.Delete("test")
 	fmt.Println(err)
 }
@@ -93,3 +96,123 @@ func TestRedisDelete(t *testing.T) {
 // 		return true
 // 	})
 // }
+
+// newStubRedis returns client of an in-process redis stand-in closed with the test
+func newStubRedis(t *testing.T, topology Topology) *rcache {
+	s, err := redisstub.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := radix.NewPool("tcp", s.Addr(), 2)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+		s.Close()
+	})
+	return &rcache{client: pool, topology: topology, servers: []string{s.Addr()}}
+}
+
+func TestRedisStubMulti(t *testing.T) {
+	for _, topology := range []Topology{Standalone, Cluster} {
+		x := newStubRedis(t, topology)
+
+		err := x.SetMulti(map[string][]byte{
+			"a":        []byte("1"),
+			"b":        []byte("2"),
+			"{user}.c": []byte("3"),
+		}, time.Hour)
+		assert.Nil(t, err)
+
+		items, err := x.GetMulti([]string{"a", "b", "{user}.c", "missing"})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string][]byte{
+			"a":        []byte("1"),
+			"b":        []byte("2"),
+			"{user}.c": []byte("3"),
+		}, items)
+
+		assert.Nil(t, x.DeleteMulti([]string{"a", "{user}.c", "missing"}))
+
+		items, _ = x.GetMulti([]string{"a", "b", "{user}.c"})
+		assert.Equal(t, map[string][]byte{"b": []byte("2")}, items)
+	}
+}
+
+func TestRedisSlotGroups(t *testing.T) {
+	x := newStubRedis(t, Cluster)
+
+	groups := x.slotGroups([]string{"{user1}.a", "{user2}.a", "{user1}.b"})
+	assert.Equal(t, [][]string{{"{user1}.a", "{user1}.b"}, {"{user2}.a"}}, groups)
+
+	x.topology = Standalone
+	groups = x.slotGroups([]string{"{user1}.a", "{user2}.a"})
+	assert.Len(t, groups, 1)
+}
+
+func TestRedisStubCounters(t *testing.T) {
+	x := newStubRedis(t, Standalone)
+
+	n, err := x.Incr("attempt", 1, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	n, _ = x.Incr("attempt", 2, time.Hour)
+	assert.Equal(t, int64(3), n)
+	n, _ = x.Decr("attempt", 1, 0)
+	assert.Equal(t, int64(2), n)
+
+	ttl, err := x.TTL("attempt")
+	assert.Nil(t, err)
+	assert.True(t, ttl > 59*time.Second && ttl <= time.Minute)
+
+	assert.Nil(t, x.Touch("attempt", 0))
+	ttl, _ = x.TTL("attempt")
+	assert.Equal(t, NoExpiration, ttl)
+
+	ttl, _ = x.TTL("missing")
+	assert.Equal(t, time.Duration(0), ttl)
+
+	ok, err := x.Exists("attempt")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, _ = x.Exists("missing")
+	assert.False(t, ok)
+}
+
+func TestRedisStubCompareAndSwap(t *testing.T) {
+	x := newStubRedis(t, Standalone)
+
+	val, token, err := x.GetWithToken("balance")
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+	assert.Nil(t, x.CompareAndSwap("balance", []byte("10"), time.Hour, token))
+	assert.Equal(t, ErrCASConflict, x.CompareAndSwap("balance", []byte("20"), time.Hour, token))
+
+	val, token, _ = x.GetWithToken("balance")
+	assert.Equal(t, "10", string(val))
+
+	//another writer changed the item in between
+	x.Set("balance", []byte("15"), time.Hour)
+	assert.Equal(t, ErrCASConflict, x.CompareAndSwap("balance", []byte("11"), time.Hour, token))
+
+	_, token, _ = x.GetWithToken("balance")
+	assert.Nil(t, x.CompareAndSwap("balance", []byte("16"), time.Hour, token))
+
+	b, _ := x.Get("balance")
+	assert.Equal(t, "16", string(b))
+	ttl, _ := x.TTL("balance")
+	assert.True(t, ttl > 59*time.Minute)
+}
+
+func TestRedisStubUnknownScript(t *testing.T) {
+	x := newStubRedis(t, Standalone)
+
+	//scripts are recognized by their exact body, not by the commands they call
+	var n int64
+	err := x.Eval(&n, NewScript(1, `return redis.call("INCRBY", KEYS[1], ARGV[1]) * 2`), []string{"a"}, "1", "0")
+	assert.NotNil(t, err)
+	assert.Nil(t, x.Eval(&n, incrScript, []string{"a"}, "1", "0"))
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,306 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/armiariyan/bepkg/logger"
+	"github.com/golang/snappy"
+)
+
+// Compression applied by Secure before encryption
+type Compression int
+
+const (
+	//NoCompression stores values uncompressed, default setting
+	NoCompression Compression = iota
+	//Snappy fast compression with moderate ratio
+	Snappy
+	//Gzip slower compression with better ratio
+	Gzip
+)
+
+// ErrDecrypt returned by Secure when a value can't be authenticated or decrypted,
+// e.g. written without Secure, with an unknown key id or tampered
+var ErrDecrypt = errors.New("cache: value can't be decrypted")
+
+// secureVersion first header byte of values written by Secure
+const secureVersion byte = 1
+
+// SecureOptions set options for Secure
+type SecureOptions struct {
+	//KeyID identifies the key in the value header, change it with the key on rotation
+	KeyID byte
+	//OldKeys previous keys by id, values encrypted with them are still readable until they expire
+	OldKeys map[byte][]byte
+
+	Compression Compression
+	//Minimum value size to be compressed, default is 1024 bytes
+	CompressThreshold int
+}
+
+// secure Keyval decorator encrypting values with AES-GCM
+type secure struct {
+	kv        Keyval
+	keyID     byte
+	aeads     map[byte]cipher.AEAD
+	compress  Compression
+	threshold int
+}
+
+// Secure encrypts values of kv with AES-GCM using key of 16, 24 or 32 bytes, the cache key
+// is authenticated too so values can't be swapped between keys. Counters of Incr and Decr
+// are stored in plain. Values are compressed before encryption when Compression is set.
+func Secure(kv Keyval, key []byte, opts SecureOptions) (Keyval, error) {
+	s := &secure{
+		kv:        kv,
+		keyID:     opts.KeyID,
+		aeads:     make(map[byte]cipher.AEAD, 1+len(opts.OldKeys)),
+		compress:  opts.Compression,
+		threshold: opts.CompressThreshold,
+	}
+	if s.threshold <= 0 {
+		s.threshold = 1024
+	}
+
+	for id, k := range opts.OldKeys {
+		if err := s.addKey(id, k); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.addKey(opts.KeyID, key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *secure) addKey(id byte, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	s.aeads[id] = aead
+	return nil
+}
+
+// header version, key id, compression
+func (s *secure) header(compress Compression) []byte {
+	return []byte{secureVersion, s.keyID, byte(compress)}
+}
+
+// additionalData binds the ciphertext to the header and the cache key
+func additionalData(header []byte, key string) []byte {
+	return append(append([]byte{}, header...), key...)
+}
+
+func (s *secure) encrypt(key string, val []byte) ([]byte, error) {
+	compress := NoCompression
+	if s.compress != NoCompression && len(val) >= s.threshold {
+		compressed, err := compressValue(s.compress, val)
+		if err != nil {
+			return nil, err
+		}
+		val, compress = compressed, s.compress
+	}
+
+	aead := s.aeads[s.keyID]
+	header := s.header(compress)
+	out := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(val)+aead.Overhead())
+	copy(out, header)
+	nonce := out[len(header):]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, val, additionalData(header, key)), nil
+}
+
+func (s *secure) decrypt(key string, data []byte) ([]byte, error) {
+	if len(data) < 3 || data[0] != secureVersion {
+		return nil, ErrDecrypt
+	}
+	header := data[:3]
+	aead, ok := s.aeads[header[1]]
+	if !ok || len(data) < len(header)+aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce := data[len(header) : len(header)+aead.NonceSize()]
+	val, err := aead.Open(nil, nonce, data[len(header)+aead.NonceSize():], additionalData(header, key))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return decompressValue(Compression(header[2]), val)
+}
+
+func compressValue(c Compression, val []byte) ([]byte, error) {
+	switch c {
+	case Snappy:
+		return snappy.Encode(nil, val), nil
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(val); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, errors.New("cache: unknown compression")
+}
+
+func decompressValue(c Compression, val []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return val, nil
+	case Snappy:
+		return snappy.Decode(nil, val)
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(val))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return ioutil.ReadAll(zr)
+	}
+	return nil, errors.New("cache: unknown compression")
+}
+
+func (s *secure) SetLogger(l logger.Logger) {
+	s.kv.SetLogger(l)
+}
+
+func (s *secure) Add(key string, val []byte, expiration time.Duration) error {
+	data, err := s.encrypt(key, val)
+	if err != nil {
+		return err
+	}
+	return s.kv.Add(key, data, expiration)
+}
+
+func (s *secure) Set(key string, val []byte, expiration time.Duration) error {
+	data, err := s.encrypt(key, val)
+	if err != nil {
+		return err
+	}
+	return s.kv.Set(key, data, expiration)
+}
+
+func (s *secure) Delete(key string) error {
+	return s.kv.Delete(key)
+}
+
+// Get the item with the provided key, ErrDecrypt if it can't be decrypted.
+// Return nil byte if the item didn't already exist in the cache.
+func (s *secure) Get(key string) ([]byte, error) {
+	return s.decryptOne(key)(s.kv.Get(key))
+}
+
+// decryptOne returns decryption of a single item read for key
+func (s *secure) decryptOne(key string) func(data []byte, err error) ([]byte, error) {
+	return func(data []byte, err error) ([]byte, error) {
+		if err != nil || data == nil {
+			return nil, err
+		}
+		return s.decrypt(key, data)
+	}
+}
+
+func (s *secure) GetMulti(keys []string) (map[string][]byte, error) {
+	return s.decryptAll(s.kv.GetMulti(keys))
+}
+
+func (s *secure) decryptAll(found map[string][]byte, err error) (map[string][]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string][]byte, len(found))
+	for key, data := range found {
+		if items[key], err = s.decrypt(key, data); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (s *secure) SetMulti(items map[string][]byte, expiration time.Duration) error {
+	encrypted := make(map[string][]byte, len(items))
+	for key, val := range items {
+		data, err := s.encrypt(key, val)
+		if err != nil {
+			return err
+		}
+		encrypted[key] = data
+	}
+	return s.kv.SetMulti(encrypted, expiration)
+}
+
+func (s *secure) DeleteMulti(keys []string) error {
+	return s.kv.DeleteMulti(keys)
+}
+
+func (s *secure) Incr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return s.kv.Incr(key, delta, expiration)
+}
+
+func (s *secure) Decr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return s.kv.Decr(key, delta, expiration)
+}
+
+func (s *secure) TTL(key string) (time.Duration, error) {
+	return s.kv.TTL(key)
+}
+
+func (s *secure) Touch(key string, expiration time.Duration) error {
+	return s.kv.Touch(key, expiration)
+}
+
+func (s *secure) Exists(key string) (bool, error) {
+	return s.kv.Exists(key)
+}
+
+// SetWithTags writes the encrypted item, ErrNotSupported when the wrapped cache isn't a Tagger
+func (s *secure) SetWithTags(key string, val []byte, expiration time.Duration, tags ...string) error {
+	t, ok := s.kv.(Tagger)
+	if !ok {
+		return ErrNotSupported
+	}
+	data, err := s.encrypt(key, val)
+	if err != nil {
+		return err
+	}
+	return t.SetWithTags(key, data, expiration, tags...)
+}
+
+// InvalidateTag invalidates every item written with the tag
+func (s *secure) InvalidateTag(tag string) error {
+	t, ok := s.kv.(Tagger)
+	if !ok {
+		return ErrNotSupported
+	}
+	return t.InvalidateTag(tag)
+}
+
+// Close closes the wrapped cache if it is closable
+func (s *secure) Close() error {
+	if c, ok := s.kv.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (s *secure) unwrap() Keyval {
+	return s.kv
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	secureKey    = []byte("0123456789abcdef0123456789abcdef")
+	secureOldKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func TestSecure(t *testing.T) {
+	kv := NewMemory(MemoryConfig{})
+	x, err := Secure(kv, secureKey, SecureOptions{KeyID: 1})
+	assert.Nil(t, err)
+
+	assert.Nil(t, x.Set("user:1", []byte("4111 1111 1111 1111"), time.Hour))
+
+	raw, _ := kv.Get("user:1")
+	assert.NotContains(t, string(raw), "4111")
+	assert.Equal(t, byte(1), raw[1])
+
+	b, err := x.Get("user:1")
+	assert.Nil(t, err)
+	assert.Equal(t, "4111 1111 1111 1111", string(b))
+
+	b, err = x.Get("missing")
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	assert.Nil(t, x.SetMulti(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, time.Hour))
+	items, err := x.GetMulti([]string{"a", "b", "c"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, items)
+}
+
+func TestSecureTampered(t *testing.T) {
+	kv := NewMemory(MemoryConfig{})
+	x, _ := Secure(kv, secureKey, SecureOptions{})
+
+	assert.Nil(t, x.Set("user:1", []byte("lorem"), time.Hour))
+	raw, _ := kv.Get("user:1")
+
+	//value moved to another key doesn't authenticate
+	kv.Set("user:2", raw, time.Hour)
+	_, err := x.Get("user:2")
+	assert.Equal(t, ErrDecrypt, err)
+
+	raw[len(raw)-1] ^= 1
+	kv.Set("user:1", raw, time.Hour)
+	_, err = x.Get("user:1")
+	assert.Equal(t, ErrDecrypt, err)
+
+	kv.Set("plain", []byte("lorem"), time.Hour)
+	_, err = x.Get("plain")
+	assert.Equal(t, ErrDecrypt, err)
+
+	_, err = Secure(kv, []byte("short"), SecureOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestSecureRotation(t *testing.T) {
+	kv := NewMemory(MemoryConfig{})
+	old, _ := Secure(kv, secureOldKey, SecureOptions{KeyID: 1})
+	assert.Nil(t, old.Set("user:1", []byte("lorem"), time.Hour))
+
+	x, err := Secure(kv, secureKey, SecureOptions{KeyID: 2, OldKeys: map[byte][]byte{1: secureOldKey}})
+	assert.Nil(t, err)
+
+	b, err := x.Get("user:1")
+	assert.Nil(t, err)
+	assert.Equal(t, "lorem", string(b))
+
+	assert.Nil(t, x.Set("user:1", []byte("ipsum"), time.Hour))
+	_, err = old.Get("user:1")
+	assert.Equal(t, ErrDecrypt, err)
+}
+
+func TestSecureCompression(t *testing.T) {
+	val := bytes.Repeat([]byte("lorem ipsum "), 500)
+	for _, c := range []Compression{Snappy, Gzip} {
+		kv := NewMemory(MemoryConfig{})
+		x, _ := Secure(kv, secureKey, SecureOptions{Compression: c})
+
+		assert.Nil(t, x.Set("big", val, time.Hour))
+		assert.Nil(t, x.Set("small", []byte("lorem"), time.Hour))
+
+		raw, _ := kv.Get("big")
+		assert.Equal(t, byte(c), raw[2])
+		assert.Less(t, len(raw), len(val)/4)
+		raw, _ = kv.Get("small")
+		assert.Equal(t, byte(NoCompression), raw[2])
+
+		b, err := x.Get("big")
+		assert.Nil(t, err)
+		assert.Equal(t, val, b)
+	}
+}
+
+func TestSecureTags(t *testing.T) {
+	kv := newStubRedis(t, Standalone)
+	x, _ := Secure(kv, secureKey, SecureOptions{})
+	tagger := x.(Tagger)
+
+	assert.Nil(t, tagger.SetWithTags("user:1", []byte("lorem"), time.Hour, "merchant:7"))
+	b, err := x.Get("user:1")
+	assert.Nil(t, err)
+	assert.Equal(t, "lorem", string(b))
+
+	assert.Nil(t, tagger.InvalidateTag("merchant:7"))
+	items, err := x.GetMulti([]string{"user:1"})
+	assert.Nil(t, err)
+	assert.Empty(t, items)
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Tagger is implemented by caches supporting tag based invalidation, e.g. redis and memcache clients
+type Tagger interface {
+	//SetWithTags writes the item, Get treats it as missing once any of its tags is invalidated
+	SetWithTags(key string, val []byte, expiration time.Duration, tags ...string) error
+	//InvalidateTag invalidates every item written with the tag in O(1)
+	InvalidateTag(tag string) error
+}
+
+// ErrInvalidTagged returned when a tagged item can't be decoded
+var ErrInvalidTagged = errors.New("cache: invalid tagged item")
+
+// tagMagic prefixes items written by SetWithTags
+var tagMagic = []byte("\xfftag\x00")
+
+// tagKeyPrefix reserved prefix of tag generation keys, printable as memcache rejects
+// control characters in keys
+const tagKeyPrefix = "_tag:"
+
+// tagKey key of the tag generation
+func tagKey(tag string) string {
+	return tagKeyPrefix + tag
+}
+
+// newGeneration returns a generation value, an evicted generation key is never
+// recreated with a value equal to the previous one
+func newGeneration() []byte {
+	return []byte(strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
+// tagGenerations returns current generation of the tags, missing generations are created
+func tagGenerations(kv Keyval, tags []string) (map[string][]byte, error) {
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, tagKey(tag))
+	}
+	gens, err := kv.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := gens[key]; ok {
+			continue
+		}
+		if err = kv.Add(key, newGeneration(), 0); err != nil {
+			return nil, err
+		}
+		missing = append(missing, key)
+	}
+	if len(missing) == 0 {
+		return gens, nil
+	}
+
+	//read back, another client may have added the generation first
+	created, err := kv.GetMulti(missing)
+	if err != nil {
+		return nil, err
+	}
+	for key, gen := range created {
+		gens[key] = gen
+	}
+	return gens, nil
+}
+
+// setWithTags writes val wrapped with the current generation of every tag
+func setWithTags(kv Keyval, key string, val []byte, expiration time.Duration, tags []string) error {
+	if len(tags) == 0 {
+		return kv.Set(key, val, expiration)
+	}
+	gens, err := tagGenerations(kv, tags)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(tagMagic)+len(val)+len(tags)*16))
+	buf.Write(tagMagic)
+	writeUvarint(buf, uint64(len(tags)))
+	for _, tag := range tags {
+		writeBytes(buf, []byte(tag))
+		writeBytes(buf, gens[tagKey(tag)])
+	}
+	buf.Write(val)
+	return kv.Set(key, buf.Bytes(), expiration)
+}
+
+// invalidateTag replaces the tag generation, items written with the previous one become stale
+func invalidateTag(kv Keyval, tag string) error {
+	return kv.Set(tagKey(tag), newGeneration(), 0)
+}
+
+func writeUvarint(buf *bytes.Buffer, n uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], n)])
+}
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	writeUvarint(buf, uint64(len(b)))
+	buf.Write(b)
+}
+
+// taggedItem decoded item written by SetWithTags
+type taggedItem struct {
+	tags map[string][]byte
+	val  []byte
+}
+
+func decodeTagged(b []byte) (*taggedItem, error) {
+	r := bytes.NewReader(b[len(tagMagic):])
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, ErrInvalidTagged
+	}
+
+	readBytes := func() ([]byte, error) {
+		size, err := binary.ReadUvarint(r)
+		if err != nil || size > uint64(r.Len()) {
+			return nil, ErrInvalidTagged
+		}
+		b := make([]byte, size)
+		r.Read(b)
+		return b, nil
+	}
+
+	item := &taggedItem{tags: make(map[string][]byte, n)}
+	for i := uint64(0); i < n; i++ {
+		tag, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		gen, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		item.tags[tagKey(string(tag))] = gen
+	}
+	item.val = b[len(b)-r.Len():]
+	return item, nil
+}
+
+func isTagged(b []byte) bool {
+	return bytes.HasPrefix(b, tagMagic)
+}
+
+// untag unwraps items written by SetWithTags, items with an invalidated tag are removed.
+// Items written by Set are kept as is, even when they look tagged but can't be decoded.
+// Generations are read within ctx.
+func untag(ctx context.Context, kv Keyval, items map[string][]byte) error {
+	tagged := map[string]*taggedItem{}
+	var keys []string
+	seen := map[string]bool{}
+	for key, val := range items {
+		if !isTagged(val) {
+			continue
+		}
+		item, err := decodeTagged(val)
+		if err != nil {
+			continue
+		}
+		tagged[key] = item
+		for k := range item.tags {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	if len(tagged) == 0 {
+		return nil
+	}
+
+	var gens map[string][]byte
+	err := doCtx(ctx, func() (err error) {
+		gens, err = kv.GetMulti(keys)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	for key, item := range tagged {
+		items[key] = item.val
+		for k, gen := range item.tags {
+			//missing generation was evicted, it may have been invalidated before
+			if current, ok := gens[k]; !ok || !bytes.Equal(current, gen) {
+				delete(items, key)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// untagOne is untag of a single item, nil val stays nil
+func untagOne(ctx context.Context, kv Keyval, key string, val []byte) ([]byte, error) {
+	if !isTagged(val) {
+		return val, nil
+	}
+	items := map[string][]byte{key: val}
+	if err := untag(ctx, kv, items); err != nil {
+		return nil, err
+	}
+	return items[key], nil
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type taggedKeyval interface {
+	Keyval
+	Tagger
+}
+
+func testTags(t *testing.T, x taggedKeyval) {
+	assert.Nil(t, x.SetWithTags("order:1", []byte("paid"), time.Hour, "merchant:7", "daily"))
+	assert.Nil(t, x.SetWithTags("order:2", []byte("new"), time.Hour, "merchant:8"))
+	assert.Nil(t, x.Set("plain", []byte("untagged"), time.Hour))
+
+	b, err := x.Get("order:1")
+	assert.Nil(t, err)
+	assert.Equal(t, "paid", string(b))
+
+	assert.Nil(t, x.InvalidateTag("merchant:7"))
+
+	b, err = x.Get("order:1")
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	items, err := x.GetMulti([]string{"order:1", "order:2", "plain"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"order:2": []byte("new"), "plain": []byte("untagged")}, items)
+
+	//evicted generation can't resurrect items invalidated before
+	assert.Nil(t, x.Delete(tagKey("merchant:8")))
+	b, _ = x.Get("order:2")
+	assert.Nil(t, b)
+
+	//written again after invalidation is valid
+	assert.Nil(t, x.SetWithTags("order:1", []byte("refunded"), time.Hour, "merchant:7"))
+	b, _ = x.Get("order:1")
+	assert.Equal(t, "refunded", string(b))
+
+	//tag generations don't collide with keys of the application
+	assert.Nil(t, x.Set("tag:merchant:7", []byte("mine"), time.Hour))
+	assert.Nil(t, x.InvalidateTag("merchant:7"))
+	b, _ = x.Get("tag:merchant:7")
+	assert.Equal(t, "mine", string(b))
+
+	//plain values which only look tagged are returned as is
+	val := append(append([]byte{}, tagMagic...), 1, 200)
+	assert.Nil(t, x.Set("raw", val, time.Hour))
+	b, err = x.Get("raw")
+	assert.Nil(t, err)
+	assert.Equal(t, val, b)
+	items, err = x.GetMulti([]string{"raw"})
+	assert.Nil(t, err)
+	assert.Equal(t, val, items["raw"])
+}
+
+func TestRedisTags(t *testing.T) {
+	testTags(t, newStubRedis(t, Standalone))
+}
+
+func TestMemcacheTags(t *testing.T) {
+	testTags(t, newStubMemcache(t))
+}
+
+func TestDecodeTaggedInvalid(t *testing.T) {
+	_, err := decodeTagged(append(append([]byte{}, tagMagic...), 1, 200))
+	assert.Equal(t, ErrInvalidTagged, err)
+}
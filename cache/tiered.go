@@ -0,0 +1,356 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/armiariyan/bepkg/logger"
+)
+
+// TieredOptions set options for two-tier cache
+type TieredOptions struct {
+	//Expiration of entries populated into the local tier, default is one minute.
+	//It is never longer than the remote expiration, except for remote tiers without TTL, e.g. memcache.
+	L1TTL time.Duration
+
+	//Redis pub/sub channel used to evict local entries on every other instance
+	//after Set/Add/Delete, empty channel disables invalidation
+	InvalidationChannel string
+}
+
+type tcache struct {
+	l1     Keyval
+	l2     Keyval
+	opts   TieredOptions
+	id     []byte
+	notify PubSub
+	unsub  context.CancelFunc
+	logger logger.Logger
+}
+
+// NewTiered create near cache serving reads from local l1 and falling back to remote l2.
+// Writes go through to both tiers. The returned Keyval also implements io.Closer
+// to stop listening to the invalidation channel.
+func NewTiered(l1, l2 Keyval, opts TieredOptions) (kv Keyval, err error) {
+	if opts.L1TTL <= 0 {
+		opts.L1TTL = time.Minute
+	}
+
+	id := make([]byte, 8)
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+
+	t := &tcache{
+		l1:   l1,
+		l2:   l2,
+		opts: opts,
+		id:   []byte(hex.EncodeToString(id)),
+	}
+
+	if opts.InvalidationChannel != "" {
+		notify, ok := l2.(PubSub)
+		if !ok {
+			return nil, errors.New("Remote cache does not support invalidation channel")
+		}
+		var ctx context.Context
+		ctx, t.unsub = context.WithCancel(context.Background())
+		t.notify = notify
+		err = notify.Subscribe(ctx, []string{opts.InvalidationChannel}, t.onInvalidate)
+		if err != nil {
+			t.unsub()
+			return
+		}
+	}
+
+	kv = t
+	return
+}
+
+func (t *tcache) SetLogger(l logger.Logger) {
+	t.logger = l
+	t.l1.SetLogger(l)
+	t.l2.SetLogger(l)
+}
+
+func (t *tcache) logError(message interface{}) {
+	if t.logger != nil {
+		t.logger.Error("tiered-cache",
+			logger.ToField("caller", logger.Caller(2)),
+			logger.ToField("message", message),
+		)
+	}
+}
+
+// Close stops listening to the invalidation channel
+func (t *tcache) Close() error {
+	if t.unsub != nil {
+		t.unsub()
+	}
+	return nil
+}
+
+// onInvalidate evicts local entry of a key changed by another instance,
+// message format is "<instance id>:<key>"
+func (t *tcache) onInvalidate(_ string, message []byte) {
+	i := bytes.IndexByte(message, ':')
+	if i < 0 || bytes.Equal(message[:i], t.id) {
+		return
+	}
+	if err := t.l1.Delete(string(message[i+1:])); err != nil {
+		t.logError(fmt.Sprintf("invalidate %s %s", message[i+1:], err.Error()))
+	}
+}
+
+func (t *tcache) invalidate(key string) {
+	if t.notify == nil {
+		return
+	}
+	msg := append(append(append([]byte(nil), t.id...), ':'), key...)
+	if err := t.notify.Publish(t.opts.InvalidationChannel, msg); err != nil {
+		t.logError(fmt.Sprintf("publish %s %s", key, err.Error()))
+	}
+}
+
+func (t *tcache) l1TTL(expiration time.Duration) time.Duration {
+	if expiration <= 0 || expiration > t.opts.L1TTL {
+		return t.opts.L1TTL
+	}
+	return expiration
+}
+
+// ttlGetter remote tier able to read items along with their remaining time to live in one round trip
+type ttlGetter interface {
+	getWithTTL(keys []string) (map[string][]byte, map[string]time.Duration, error)
+}
+
+// remoteGet reads the keys from remote tier along with their remaining remote expiration.
+// Remote tiers other than redis, including decorated ones, are asked the TTL of every found key.
+func (t *tcache) remoteGet(keys []string) (items map[string][]byte, ttls map[string]time.Duration, err error) {
+	if r, ok := t.l2.(ttlGetter); ok {
+		return r.getWithTTL(keys)
+	}
+
+	if len(keys) == 1 {
+		var val []byte
+		if val, err = t.l2.Get(keys[0]); err == nil && val != nil {
+			items = map[string][]byte{keys[0]: val}
+		}
+	} else {
+		items, err = t.l2.GetMulti(keys)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ttls = make(map[string]time.Duration, len(items))
+	for key := range items {
+		ttl, err := t.l2.TTL(key)
+		if err != nil {
+			ttl = NoExpiration
+		}
+		ttls[key] = ttl
+	}
+	return
+}
+
+// fill keeps items read from remote tier locally, capped by the remaining remote expiration.
+// Items which just expired remotely are not kept.
+func (t *tcache) fill(items map[string][]byte, ttls map[string]time.Duration) {
+	for key, val := range items {
+		ttl := ttls[key]
+		switch {
+		case ttl == NoExpiration:
+			ttl = t.opts.L1TTL
+		case ttl <= 0:
+			continue
+		default:
+			ttl = t.l1TTL(ttl)
+		}
+		if err := t.l1.Set(key, val, ttl); err != nil {
+			t.logError(fmt.Sprintf("%s %s", key, err.Error()))
+		}
+	}
+}
+
+// Get the item from local tier, otherwise from remote tier and keep it locally.
+// Return nil byte if the item didn't already exist in the cache.
+func (t *tcache) Get(key string) ([]byte, error) {
+	val, err := t.l1.Get(key)
+	if err == nil && val != nil {
+		return val, nil
+	}
+
+	items, ttls, err := t.remoteGet([]string{key})
+	if err != nil {
+		return nil, err
+	}
+	t.fill(items, ttls)
+
+	return items[key], nil
+}
+
+// Add writes the given item to remote tier, if no value already exists for its key.
+// The local entry is dropped so the next Get reads the stored value.
+func (t *tcache) Add(key string, val []byte, expiration time.Duration) error {
+	if err := t.l2.Add(key, val, expiration); err != nil {
+		return err
+	}
+
+	if err := t.l1.Delete(key); err != nil {
+		t.logError(fmt.Sprintf("%s %s", key, err.Error()))
+	}
+	t.invalidate(key)
+
+	return nil
+}
+
+// Set writes the given item to both tiers, unconditionally.
+func (t *tcache) Set(key string, val []byte, expiration time.Duration) error {
+	if err := t.l2.Set(key, val, expiration); err != nil {
+		return err
+	}
+
+	if err := t.l1.Set(key, val, t.l1TTL(expiration)); err != nil {
+		t.logError(fmt.Sprintf("%s %s", key, err.Error()))
+	}
+	t.invalidate(key)
+
+	return nil
+}
+
+// Delete deletes the item with the provided key from both tiers.
+func (t *tcache) Delete(key string) error {
+	if err := t.l2.Delete(key); err != nil {
+		return err
+	}
+
+	if err := t.l1.Delete(key); err != nil {
+		t.logError(fmt.Sprintf("%s %s", key, err.Error()))
+	}
+	t.invalidate(key)
+
+	return nil
+}
+
+// GetMulti gets the items from local tier, missing keys are read from remote tier and kept locally.
+// Keys which didn't exist in the cache are omitted from the result.
+func (t *tcache) GetMulti(keys []string) (map[string][]byte, error) {
+	items, err := t.l1.GetMulti(keys)
+	if err != nil {
+		t.logError(fmt.Sprintf("%v %s", keys, err.Error()))
+		items = map[string][]byte{}
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := items[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return items, nil
+	}
+
+	remote, ttls, err := t.remoteGet(missing)
+	if err != nil {
+		return nil, err
+	}
+	for key, val := range remote {
+		items[key] = val
+	}
+	t.fill(remote, ttls)
+
+	return items, nil
+}
+
+// SetMulti writes the given items to both tiers, unconditionally.
+func (t *tcache) SetMulti(items map[string][]byte, expiration time.Duration) error {
+	if err := t.l2.SetMulti(items, expiration); err != nil {
+		return err
+	}
+
+	if err := t.l1.SetMulti(items, t.l1TTL(expiration)); err != nil {
+		t.logError(err.Error())
+	}
+	for key := range items {
+		t.invalidate(key)
+	}
+
+	return nil
+}
+
+// DeleteMulti deletes the items with the provided keys from both tiers.
+func (t *tcache) DeleteMulti(keys []string) error {
+	if err := t.l2.DeleteMulti(keys); err != nil {
+		return err
+	}
+
+	if err := t.l1.DeleteMulti(keys); err != nil {
+		t.logError(fmt.Sprintf("%v %s", keys, err.Error()))
+	}
+	for _, key := range keys {
+		t.invalidate(key)
+	}
+
+	return nil
+}
+
+// Incr increments the counter in remote tier, the local entry is dropped
+func (t *tcache) Incr(key string, delta int64, expiration time.Duration) (int64, error) {
+	count, err := t.l2.Incr(key, delta, expiration)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := t.l1.Delete(key); err != nil {
+		t.logError(fmt.Sprintf("%s %s", key, err.Error()))
+	}
+	t.invalidate(key)
+
+	return count, nil
+}
+
+// Decr decrements the counter in remote tier, the local entry is dropped
+func (t *tcache) Decr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return t.Incr(key, -delta, expiration)
+}
+
+// TTL returns remaining time to live in remote tier
+func (t *tcache) TTL(key string) (time.Duration, error) {
+	return t.l2.TTL(key)
+}
+
+// Touch resets the expiration in remote tier, the local entry is dropped
+// so it is read again with the new expiration
+func (t *tcache) Touch(key string, expiration time.Duration) error {
+	if err := t.l2.Touch(key, expiration); err != nil {
+		return err
+	}
+
+	if err := t.l1.Delete(key); err != nil {
+		t.logError(fmt.Sprintf("%s %s", key, err.Error()))
+	}
+	t.invalidate(key)
+
+	return nil
+}
+
+// Exists reports whether the key exists in remote tier
+func (t *tcache) Exists(key string) (bool, error) {
+	return t.l2.Exists(key)
+}
+
+// unwrap returns the remote tier, shared by every instance for locking
+func (t *tcache) unwrap() Keyval {
+	return t.l2
+}
+
+func (t *tcache) cacheLogger() logger.Logger {
+	return t.logger
+}
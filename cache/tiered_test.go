@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// busCache memory cache with in-process pub/sub standing in for redis
+type busCache struct {
+	Keyval
+	mu   sync.Mutex
+	subs map[string][]func(string, []byte)
+}
+
+func (b *busCache) Publish(channel string, payload []byte) error {
+	b.mu.Lock()
+	subs := b.subs[channel]
+	b.mu.Unlock()
+	for _, fn := range subs {
+		fn(channel, payload)
+	}
+	return nil
+}
+
+func (b *busCache) Subscribe(ctx context.Context, channels []string, handler func(string, []byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = map[string][]func(string, []byte){}
+	}
+	for _, channel := range channels {
+		b.subs[channel] = append(b.subs[channel], handler)
+	}
+	return nil
+}
+
+func TestTieredReadThrough(t *testing.T) {
+	l1 := NewMemory(MemoryConfig{})
+	l2 := NewMemory(MemoryConfig{})
+	x, err := NewTiered(l1, l2, TieredOptions{L1TTL: time.Minute})
+	assert.Nil(t, err)
+
+	l2.Set("test", []byte("remote"), time.Hour)
+
+	b, err := x.Get("test")
+	assert.Nil(t, err)
+	assert.Equal(t, "remote", string(b))
+
+	b, _ = l1.Get("test")
+	assert.Equal(t, "remote", string(b))
+
+	b, err = x.Get("missing")
+	assert.Nil(t, b)
+	assert.Nil(t, err)
+
+	//local entry doesn't outlive the remote one
+	l2.Set("short", []byte("remote"), 50*time.Millisecond)
+	x.Get("short")
+	ttl, _ := l1.TTL("short")
+	assert.True(t, ttl > 0 && ttl <= 50*time.Millisecond, "local ttl %v", ttl)
+}
+
+func TestTieredWriteThrough(t *testing.T) {
+	l1 := NewMemory(MemoryConfig{})
+	l2 := NewMemory(MemoryConfig{})
+	x, _ := NewTiered(l1, l2, TieredOptions{})
+
+	x.Set("test", []byte("ini lagi"), time.Hour)
+	b, _ := l1.Get("test")
+	assert.Equal(t, "ini lagi", string(b))
+	b, _ = l2.Get("test")
+	assert.Equal(t, "ini lagi", string(b))
+
+	x.Add("test", []byte("ignored"), time.Hour)
+	b, _ = x.Get("test")
+	assert.Equal(t, "ini lagi", string(b))
+
+	x.Delete("test")
+	b, _ = l1.Get("test")
+	assert.Nil(t, b)
+	b, _ = l2.Get("test")
+	assert.Nil(t, b)
+}
+
+func TestTieredInvalidation(t *testing.T) {
+	remote := &busCache{Keyval: NewMemory(MemoryConfig{})}
+	podA := NewMemory(MemoryConfig{})
+	podB := NewMemory(MemoryConfig{})
+
+	a, err := NewTiered(podA, remote, TieredOptions{InvalidationChannel: "invalidate"})
+	assert.Nil(t, err)
+	b, err := NewTiered(podB, remote, TieredOptions{InvalidationChannel: "invalidate"})
+	assert.Nil(t, err)
+
+	a.Set("test", []byte("one"), time.Hour)
+	val, _ := b.Get("test")
+	assert.Equal(t, "one", string(val))
+
+	a.Set("test", []byte("two"), time.Hour)
+	val, _ = b.Get("test")
+	assert.Equal(t, "two", string(val))
+	val, _ = podA.Get("test")
+	assert.Equal(t, "two", string(val))
+
+	a.Delete("test")
+	val, _ = b.Get("test")
+	assert.Nil(t, val)
+
+	_, err = NewTiered(podA, NewMemory(MemoryConfig{}), TieredOptions{InvalidationChannel: "invalidate"})
+	assert.NotNil(t, err)
+}
+
+func TestTieredMulti(t *testing.T) {
+	l1 := NewMemory(MemoryConfig{})
+	l2 := NewMemory(MemoryConfig{})
+	x, _ := NewTiered(l1, l2, TieredOptions{})
+
+	l1.Set("a", []byte("local"), time.Hour)
+	l2.SetMulti(map[string][]byte{"a": []byte("remote"), "b": []byte("2")}, time.Hour)
+
+	items, err := x.GetMulti([]string{"a", "b", "c"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("local"), "b": []byte("2")}, items)
+
+	b, _ := l1.Get("b")
+	assert.Equal(t, "2", string(b))
+
+	l2.Set("short", []byte("remote"), 50*time.Millisecond)
+	x.GetMulti([]string{"short"})
+	ttl, _ := l1.TTL("short")
+	assert.True(t, ttl > 0 && ttl <= 50*time.Millisecond, "local ttl %v", ttl)
+
+	x.DeleteMulti([]string{"a", "b"})
+	items, _ = x.GetMulti([]string{"a", "b"})
+	assert.Empty(t, items)
+}
+
+func TestTieredRedis(t *testing.T) {
+	l1 := NewMemory(MemoryConfig{})
+	l2 := newStubRedis(t, Standalone)
+	x, _ := NewTiered(l1, l2, TieredOptions{L1TTL: time.Minute})
+
+	l2.Set("a", []byte("1"), time.Hour)
+	l2.Set("short", []byte("2"), 2*time.Second)
+
+	b, err := x.Get("short")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", string(b))
+	ttl, _ := l1.TTL("short")
+	assert.True(t, ttl > 0 && ttl <= 2*time.Second, "local ttl %v", ttl)
+
+	b, err = x.Get("missing")
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	l1.Delete("short")
+	items, err := x.GetMulti([]string{"a", "short", "missing"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "short": []byte("2")}, items)
+	ttl, _ = l1.TTL("a")
+	assert.True(t, ttl > 59*time.Second && ttl <= time.Minute, "local ttl %v", ttl)
+	ttl, _ = l1.TTL("short")
+	assert.True(t, ttl > 0 && ttl <= 2*time.Second, "local ttl %v", ttl)
+}
+
+func TestTieredTouch(t *testing.T) {
+	l1 := NewMemory(MemoryConfig{})
+	l2 := NewMemory(MemoryConfig{})
+	x, _ := NewTiered(l1, l2, TieredOptions{})
+
+	x.Set("test", []byte("1"), time.Hour)
+	assert.Nil(t, x.Touch("test", 50*time.Millisecond))
+	b, _ := l1.Get("test")
+	assert.Nil(t, b)
+
+	x.Get("test")
+	ttl, _ := l1.TTL("test")
+	assert.True(t, ttl > 0 && ttl <= 50*time.Millisecond, "local ttl %v", ttl)
+}
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ErrNotFound returned by Typed when the item didn't exist in the cache
+var ErrNotFound = errors.New("cache: item not found")
+
+// Codec serializes values stored through Typed
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec json codec backed by jsoniter
+type JSONCodec struct{}
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// Marshal encode v as json
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal decode json data into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// GobCodec encoding/gob codec, types stored in interface fields must be registered with gob.Register
+type GobCodec struct{}
+
+// Marshal encode v as gob
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decode gob data into v
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// header bytes prepended by GzipCodec to tell raw and compressed payloads apart
+const (
+	gzipRaw byte = iota
+	gzipCompressed
+)
+
+// GzipCodec wraps a codec and gzip compresses encoded values larger than Threshold bytes
+type GzipCodec struct {
+	Codec Codec
+	//Minimum encoded size to be compressed, default is 1024 bytes
+	Threshold int
+}
+
+// Marshal encode v with the wrapped codec, compressing large payloads
+func (c GzipCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := c.Threshold
+	if threshold <= 0 {
+		threshold = 1024
+	}
+	if len(data) < threshold {
+		return append([]byte{gzipRaw}, data...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(gzipCompressed)
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decompress data if needed and decode it with the wrapped codec
+func (c GzipCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return errors.New("cache: empty gzip codec payload")
+	}
+
+	switch data[0] {
+	case gzipRaw:
+		return c.Codec.Unmarshal(data[1:], v)
+	case gzipCompressed:
+		zr, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		raw, err := ioutil.ReadAll(zr)
+		if err != nil {
+			return err
+		}
+		return c.Codec.Unmarshal(raw, v)
+	}
+	return errors.New("cache: unknown gzip codec header")
+}
+
+// Typed stores values of type T in a Keyval using a Codec
+type Typed[T any] struct {
+	kv    Keyval
+	codec Codec
+}
+
+// NewTyped create typed wrapper around kv, codec defaults to JSONCodec
+func NewTyped[T any](kv Keyval, codec Codec) *Typed[T] {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &Typed[T]{kv: kv, codec: codec}
+}
+
+// Get the item with the provided key.
+// Return ErrNotFound if the item didn't already exist in the cache.
+func (t *Typed[T]) Get(key string) (val T, err error) {
+	data, err := t.kv.Get(key)
+	if err != nil {
+		return
+	}
+	if data == nil {
+		err = ErrNotFound
+		return
+	}
+	err = t.codec.Unmarshal(data, &val)
+	return
+}
+
+// Add writes the given item, if no value already exists for its key.
+func (t *Typed[T]) Add(key string, val T, expiration time.Duration) error {
+	data, err := t.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return t.kv.Add(key, data, expiration)
+}
+
+// Set writes the given item, unconditionally.
+func (t *Typed[T]) Set(key string, val T, expiration time.Duration) error {
+	data, err := t.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return t.kv.Set(key, data, expiration)
+}
+
+// Delete deletes the item with the provided key.
+func (t *Typed[T]) Delete(key string) error {
+	return t.kv.Delete(key)
+}
+
+// GetMulti gets the items with the provided keys.
+// Keys which didn't exist in the cache are omitted from the result.
+func (t *Typed[T]) GetMulti(keys []string) (map[string]T, error) {
+	items, err := t.kv.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make(map[string]T, len(items))
+	for key, data := range items {
+		var val T
+		if err = t.codec.Unmarshal(data, &val); err != nil {
+			return nil, err
+		}
+		vals[key] = val
+	}
+	return vals, nil
+}
+
+// SetMulti writes the given items, unconditionally.
+func (t *Typed[T]) SetMulti(vals map[string]T, expiration time.Duration) error {
+	items := make(map[string][]byte, len(vals))
+	for key, val := range vals {
+		data, err := t.codec.Marshal(val)
+		if err != nil {
+			return err
+		}
+		items[key] = data
+	}
+	return t.kv.SetMulti(items, expiration)
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedUser struct {
+	ID   int
+	Name string
+	Tags []string
+}
+
+func TestTypedCodecs(t *testing.T) {
+	codecs := map[string]Codec{
+		"json": JSONCodec{},
+		"gob":  GobCodec{},
+		"gzip": GzipCodec{Codec: JSONCodec{}, Threshold: 16},
+	}
+
+	for name, codec := range codecs {
+		x := NewTyped[typedUser](NewMemory(MemoryConfig{}), codec)
+
+		_, err := x.Get("user")
+		assert.Equal(t, ErrNotFound, err, name)
+
+		user := typedUser{ID: 1, Name: strings.Repeat("lorem ", 50), Tags: []string{"a", "b"}}
+		assert.Nil(t, x.Set("user", user, time.Hour), name)
+
+		got, err := x.Get("user")
+		assert.Nil(t, err, name)
+		assert.Equal(t, user, got, name)
+
+		x.SetMulti(map[string]typedUser{"a": {ID: 2}, "b": {ID: 3}}, time.Hour)
+		users, err := x.GetMulti([]string{"a", "b", "c"})
+		assert.Nil(t, err, name)
+		assert.Equal(t, map[string]typedUser{"a": {ID: 2}, "b": {ID: 3}}, users, name)
+	}
+}
+
+func TestGzipCodec(t *testing.T) {
+	codec := GzipCodec{Codec: JSONCodec{}}
+
+	small, _ := codec.Marshal("small")
+	assert.Equal(t, gzipRaw, small[0])
+
+	long := strings.Repeat("lorem ipsum ", 200)
+	large, _ := codec.Marshal(long)
+	assert.Equal(t, gzipCompressed, large[0])
+	assert.True(t, len(large) < len(long))
+
+	var got string
+	assert.Nil(t, codec.Unmarshal(large, &got))
+	assert.Equal(t, long, got)
+}
@@ -33,9 +33,12 @@ func connMock(t *testing.T) (client Client) {
 	}
 	client.DB("test").Collection("trainers")
 
-	logr := logger.New(logger.Options{
+	logr, err := logger.New(logger.Options{
 		Stdout: true,
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 	client.Debug(true)
 
 	client.SetLogger(logr)
@@ -0,0 +1,207 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Overflow policies of async logging
+const (
+	//OverflowDrop drops lines while the queue is full, counted by Logger.Dropped
+	OverflowDrop = "drop"
+	//OverflowBlock makes logging calls wait for room in the queue
+	OverflowBlock = "block"
+)
+
+// asyncBatchSize bytes written at once by async writers
+const asyncBatchSize = 256 * 1024
+
+var errLoggerClosed = errors.New("logger closed")
+
+// asyncWrapper wraps writers of a logger in async writers sharing the dropped counter
+type asyncWrapper struct {
+	opts    AsyncOptions
+	dropped uint64
+}
+
+func newAsyncWrapper(opts AsyncOptions) (*asyncWrapper, error) {
+	if !opts.Enabled {
+		return nil, nil
+	}
+	switch opts.Overflow {
+	case "":
+		opts.Overflow = OverflowDrop
+	case OverflowDrop, OverflowBlock:
+	default:
+		return nil, fmt.Errorf("unknown log overflow policy %q", opts.Overflow)
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 8192
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	return &asyncWrapper{opts: opts}, nil
+}
+
+// wrap returns ws written in background, closing the async writer before closer
+func (a *asyncWrapper) wrap(ws zapcore.WriteSyncer, closer io.Closer) (zapcore.WriteSyncer, io.Closer) {
+	if a == nil {
+		return ws, closer
+	}
+	w := newAsyncWriter(ws, a.opts, &a.dropped)
+	return w, closerFunc(func() error {
+		err := w.Close()
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	})
+}
+
+func (a *asyncWrapper) droppedLines() uint64 {
+	if a == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&a.dropped)
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// asyncWriter queues lines and writes them in batches from a single goroutine
+type asyncWriter struct {
+	ws       zapcore.WriteSyncer
+	queue    chan []byte
+	block    bool
+	interval time.Duration
+	dropped  *uint64
+
+	flush     chan chan error
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newAsyncWriter(ws zapcore.WriteSyncer, opts AsyncOptions, dropped *uint64) *asyncWriter {
+	w := &asyncWriter{
+		ws:       ws,
+		queue:    make(chan []byte, opts.QueueSize),
+		block:    opts.Overflow == OverflowBlock,
+		interval: opts.FlushInterval,
+		dropped:  dropped,
+		flush:    make(chan chan error),
+		done:     make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.loop()
+	return w
+}
+
+// Write queues a copy of p, zap reuses its buffers
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.done:
+		return 0, errLoggerClosed
+	default:
+	}
+
+	line := append([]byte(nil), p...)
+	if w.block {
+		select {
+		case w.queue <- line:
+		case <-w.done:
+			return 0, errLoggerClosed
+		}
+		return len(p), nil
+	}
+
+	select {
+	case w.queue <- line:
+	default:
+		atomic.AddUint64(w.dropped, 1)
+	}
+	return len(p), nil
+}
+
+func (w *asyncWriter) loop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var buf bytes.Buffer
+	for {
+		select {
+		case line := <-w.queue:
+			buf.Write(line)
+			if buf.Len() >= asyncBatchSize {
+				w.write(&buf)
+			}
+		case <-ticker.C:
+			w.write(&buf)
+		case reply := <-w.flush:
+			w.drain(&buf)
+			reply <- w.ws.Sync()
+		case <-w.done:
+			w.drain(&buf)
+			w.ws.Sync()
+			return
+		}
+	}
+}
+
+// drain writes the buffered and queued lines
+func (w *asyncWriter) drain(buf *bytes.Buffer) {
+	for {
+		select {
+		case line := <-w.queue:
+			buf.Write(line)
+		default:
+			w.write(buf)
+			return
+		}
+	}
+}
+
+func (w *asyncWriter) write(buf *bytes.Buffer) {
+	if buf.Len() == 0 {
+		return
+	}
+	if _, err := w.ws.Write(buf.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v write log: %v\n", time.Now(), err)
+	}
+	buf.Reset()
+}
+
+// Sync writes the queued lines and syncs the wrapped writer
+func (w *asyncWriter) Sync() error {
+	reply := make(chan error, 1)
+	select {
+	case w.flush <- reply:
+		return <-reply
+	case <-w.done:
+		return nil
+	}
+}
+
+// Close writes the queued lines and stops the writer
+func (w *asyncWriter) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+	})
+	return nil
+}
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type syncBuffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	synced int
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.synced++
+	return nil
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAsyncWriterSync(t *testing.T) {
+	var out syncBuffer
+	var dropped uint64
+	w := newAsyncWriter(&out, AsyncOptions{QueueSize: 16, FlushInterval: time.Hour}, &dropped)
+
+	w.Write([]byte("first\n"))
+	w.Write([]byte("second\n"))
+	assert.Nil(t, w.Sync())
+	assert.Equal(t, "first\nsecond\n", out.String())
+	assert.Equal(t, 1, out.synced)
+
+	w.Write([]byte("third\n"))
+	assert.Nil(t, w.Close())
+	assert.Equal(t, "first\nsecond\nthird\n", out.String())
+
+	_, err := w.Write([]byte("closed\n"))
+	assert.Equal(t, errLoggerClosed, err)
+	assert.Nil(t, w.Close())
+}
+
+func TestAsyncWriterOverflow(t *testing.T) {
+	var dropped uint64
+	//writers without loop keep the queue full
+	w := &asyncWriter{queue: make(chan []byte, 1), dropped: &dropped, done: make(chan struct{})}
+	w.Write([]byte("queued\n"))
+	n, err := w.Write([]byte("dropped\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 8, n)
+	assert.Equal(t, uint64(1), dropped)
+
+	w.block = true
+	written := make(chan error)
+	go func() {
+		_, err := w.Write([]byte("blocked\n"))
+		written <- err
+	}()
+	select {
+	case <-written:
+		t.Fatal("write didn't block on full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-w.queue
+	assert.Nil(t, <-written)
+	assert.Equal(t, "blocked\n", string(<-w.queue))
+}
+
+func TestAsyncLogger(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(Options{
+		Async:    AsyncOptions{Enabled: true, Overflow: OverflowBlock, FlushInterval: time.Hour},
+		Sinks:    []SinkOptions{{Type: SinkFile, Path: filepath.Join(dir, "app.log"), Encoding: EncodingJSON}},
+		TdrSinks: []SinkOptions{{Type: SinkFile, Path: filepath.Join(dir, "tdr.log"), Encoding: EncodingJSON}},
+	})
+	assert.Nil(t, err)
+
+	l.Info("before sync")
+	app, _ := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Empty(t, app)
+	assert.Nil(t, l.Sync())
+	app, _ = ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Contains(t, string(app), "before sync")
+
+	for i := 0; i < 100; i++ {
+		l.TDR(LogTdrModel{ThreadID: "abc"})
+	}
+	assert.Nil(t, l.Close())
+	tdr, _ := ioutil.ReadFile(filepath.Join(dir, "tdr.log"))
+	assert.Equal(t, 100, strings.Count(string(tdr), `"xid":"abc"`))
+	assert.Equal(t, uint64(0), l.Dropped())
+
+	_, err = New(Options{Async: AsyncOptions{Enabled: true, Overflow: "spill"}, Stdout: true})
+	assert.NotNil(t, err)
+}
+
+func TestNewUnwritableFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	assert.Nil(t, ioutil.WriteFile(file, nil, 0644))
+
+	_, err := New(Options{FileLocation: filepath.Join(file, "app.log"), FileTdrLocation: filepath.Join(file, "tdr.log")})
+	assert.NotNil(t, err)
+
+	l, err := New(Options{
+		FileLocation:    filepath.Join(filepath.Dir(file), "app.log"),
+		FileTdrLocation: filepath.Join(file, "tdr.log"),
+	})
+	assert.NotNil(t, err)
+	assert.Nil(t, l)
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	threadIDKey
+	traceKey
+)
+
+// field keys of the ids added by context-aware logging calls
+const (
+	ThreadIDKey = "_app_thread_id"
+	TraceIDKey  = "_trace_id"
+	SpanIDKey   = "_span_id"
+)
+
+type trace struct {
+	traceID, spanID string
+}
+
+// WithContext returns ctx carrying l, read it back with FromContext
+func WithContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the logger of ctx with its thread and trace ids,
+// a logger discarding everything when ctx carries none
+func FromContext(ctx context.Context) Logger {
+	l, ok := ctx.Value(loggerKey).(Logger)
+	if !ok {
+		return Nop()
+	}
+	if fields := contextFields(ctx); len(fields) > 0 {
+		return l.With(fields...)
+	}
+	return l
+}
+
+// ContextWithThreadID returns ctx carrying the thread id of the request
+func ContextWithThreadID(ctx context.Context, threadID string) context.Context {
+	return context.WithValue(ctx, threadIDKey, threadID)
+}
+
+// ThreadIDFromContext returns the thread id of ctx, empty when ctx carries none
+func ThreadIDFromContext(ctx context.Context) string {
+	threadID, _ := ctx.Value(threadIDKey).(string)
+	return threadID
+}
+
+// ContextWithTrace returns ctx carrying the trace and span ids, e.g. of the incoming traceparent
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceKey, trace{traceID: traceID, spanID: spanID})
+}
+
+// contextFields returns fields of the ids carried by ctx
+func contextFields(ctx context.Context) []zap.Field {
+	if ctx == nil {
+		return nil
+	}
+	var fields []zap.Field
+	if threadID := ThreadIDFromContext(ctx); threadID != "" {
+		fields = append(fields, zap.String(ThreadIDKey, threadID))
+	}
+	if t, ok := ctx.Value(traceKey).(trace); ok {
+		if t.traceID != "" {
+			fields = append(fields, zap.String(TraceIDKey, t.traceID))
+		}
+		if t.spanID != "" {
+			fields = append(fields, zap.String(SpanIDKey, t.spanID))
+		}
+	}
+	return fields
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newJSONLogger(t *testing.T) (Logger, func() []map[string]interface{}) {
+	enc, err := getEncoder(EncodingJSON, EncoderKeys{Time: "-", Caller: "-"})
+	assert.Nil(t, err)
+	main, buf := newBufferLogger(enc)
+
+	lines := func() []map[string]interface{} {
+		var out []map[string]interface{}
+		for _, line := range splitLines(buf.String()) {
+			var m map[string]interface{}
+			assert.Nil(t, json.Unmarshal([]byte(line), &m))
+			out = append(out, m)
+		}
+		buf.Reset()
+		return out
+	}
+	return &zapLogger{logger: main, loggerTdr: zap.NewNop(), level: zap.NewAtomicLevel()}, lines
+}
+
+func splitLines(s string) (lines []string) {
+	for len(s) > 0 {
+		i := 0
+		for i < len(s) && s[i] != '\n' {
+			i++
+		}
+		lines = append(lines, s[:i])
+		if i < len(s) {
+			i++
+		}
+		s = s[i:]
+	}
+	return
+}
+
+func TestWith(t *testing.T) {
+	l, lines := newJSONLogger(t)
+
+	scoped := l.With(zap.String("_app_method", "GET"))
+	scoped.Info("first", zap.Int("n", 1))
+	l.Info("second")
+
+	out := lines()
+	assert.Equal(t, "GET", out[0]["_app_method"])
+	assert.Equal(t, float64(1), out[0]["n"])
+	assert.NotContains(t, out[1], "_app_method")
+}
+
+func TestWithClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	l, err := New(Options{
+		Sinks:    []SinkOptions{{Type: SinkFile, Path: path, Encoding: EncodingLogfmt}},
+		TdrSinks: []SinkOptions{{Type: SinkStdout}},
+	})
+	assert.Nil(t, err)
+
+	//derived logger doesn't close the outputs of the root
+	scoped := l.With(zap.String("_app_method", "GET"))
+	scoped.Info("first")
+	assert.Nil(t, scoped.Close())
+	l.Info("second")
+	assert.Nil(t, l.Close())
+
+	b, _ := ioutil.ReadFile(path)
+	assert.Contains(t, string(b), "first")
+	assert.Contains(t, string(b), "second")
+}
+
+func TestFromContext(t *testing.T) {
+	l, lines := newJSONLogger(t)
+
+	ctx := ContextWithThreadID(context.Background(), "abc")
+	ctx = ContextWithTrace(ctx, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+
+	FromContext(WithContext(ctx, l)).Info("repository")
+	l.ErrorCtx(ctx, "client", zap.String("code", "99"))
+	l.InfoCtx(context.Background(), "plain")
+
+	out := lines()
+	assert.Equal(t, "abc", out[0][ThreadIDKey])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", out[0][TraceIDKey])
+	assert.Equal(t, "00f067aa0ba902b7", out[0][SpanIDKey])
+	assert.Equal(t, "abc", out[1][ThreadIDKey])
+	assert.Equal(t, "99", out[1]["code"])
+	assert.NotContains(t, out[2], ThreadIDKey)
+
+	assert.Equal(t, "abc", ThreadIDFromContext(ctx))
+	assert.NotPanics(t, func() { FromContext(context.Background()).Info("discarded") })
+}
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// apply sets the configured keys, "-" omits the field
+func (k EncoderKeys) apply(cfg *zapcore.EncoderConfig) {
+	for _, key := range []struct {
+		dst *string
+		val string
+	}{
+		{&cfg.TimeKey, k.Time},
+		{&cfg.LevelKey, k.Level},
+		{&cfg.MessageKey, k.Message},
+		{&cfg.CallerKey, k.Caller},
+		{&cfg.NameKey, k.Name},
+		{&cfg.StacktraceKey, k.Stacktrace},
+	} {
+		switch key.val {
+		case "":
+		case "-":
+			*key.dst = ""
+		default:
+			*key.dst = key.val
+		}
+	}
+}
+
+// newEncoder creates encoder of the encoding, console when empty
+func newEncoder(encoding string, cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	switch encoding {
+	case "", EncodingConsole:
+		return zapcore.NewConsoleEncoder(cfg), nil
+	case EncodingJSON:
+		return zapcore.NewJSONEncoder(cfg), nil
+	case EncodingLogfmt:
+		return newLogfmtEncoder(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown log encoding %q", encoding)
+}
+
+func getEncoder(encoding string, keys EncoderKeys) (zapcore.Encoder, error) {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	keys.apply(&encoderConfig)
+	return newEncoder(encoding, encoderConfig)
+}
+
+func getTdrEncoder(encoding string, keys EncoderKeys) (zapcore.Encoder, error) {
+	tdrConfig := zapcore.EncoderConfig{
+		TimeKey:        "time",
+		MessageKey:     "message",
+		EncodeDuration: MillisDurationEncoder,
+		EncodeTime:     TDRLogTimeEncoder,
+		LineEnding:     zapcore.DefaultLineEnding,
+	}
+	keys.apply(&tdrConfig)
+	return newEncoder(encoding, tdrConfig)
+}
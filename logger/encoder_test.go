@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newBufferLogger(enc zapcore.Encoder) (*zap.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return zap.New(zapcore.NewCore(enc, zapcore.AddSync(&buf), zapcore.DebugLevel)), &buf
+}
+
+func TestTdrJSONEncoder(t *testing.T) {
+	enc, err := getTdrEncoder(EncodingJSON, EncoderKeys{Time: "@timestamp", Message: "-"})
+	assert.Nil(t, err)
+	tdr, buf := newBufferLogger(enc)
+
+	l := &zapLogger{loggerTdr: tdr}
+	l.TDR(LogTdrModel{
+		AppName:  "Testing",
+		ThreadID: "abc",
+		RespTime: 17,
+		Header:   map[string]string{"a": "b"},
+		Request:  `{"action": "hello"}`,
+		Response: "plain",
+	})
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Contains(t, line, "@timestamp")
+	assert.NotContains(t, line, "message")
+	assert.Equal(t, "abc", line["xid"])
+	assert.Equal(t, float64(17), line["rt"])
+	assert.Equal(t, map[string]interface{}{"a": "b"}, line["header"])
+	assert.Equal(t, map[string]interface{}{"action": "hello"}, line["req"])
+	assert.Equal(t, "plain", line["resp"])
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	enc, err := getEncoder(EncodingLogfmt, EncoderKeys{Time: "-", Caller: "-", Message: "msg"})
+	assert.Nil(t, err)
+	l, buf := newBufferLogger(enc)
+
+	l.With(zap.String("xid", "abc")).Info("cache miss",
+		zap.Int("port", 80),
+		zap.String("path", "/v1/check health"),
+		zap.Bool("ok", true),
+		zap.Any("header", map[string]string{"a": "b"}),
+		zap.Duration("took", 1500*time.Millisecond),
+		zap.Namespace("db"),
+		zap.String("name", `say "hi"`),
+	)
+
+	assert.Equal(t, `level=info msg="cache miss" xid=abc port=80 path="/v1/check health" ok=true `+
+		`header="{\"a\":\"b\"}" took=1.5 db.name="say \"hi\""`+"\n", buf.String())
+}
+
+func TestLogfmtTdrEncoder(t *testing.T) {
+	enc, err := getTdrEncoder(EncodingLogfmt, EncoderKeys{})
+	assert.Nil(t, err)
+	tdr, buf := newBufferLogger(enc)
+
+	l := &zapLogger{loggerTdr: tdr}
+	l.TDR(LogTdrModel{ThreadID: "abc", Path: "/v1/check/health"})
+
+	assert.True(t, strings.HasPrefix(buf.String(), "time="))
+	assert.Contains(t, buf.String(), `message=| xid=abc rt=0`)
+	assert.Contains(t, buf.String(), `path=/v1/check/health`)
+}
+
+func TestUnknownEncoding(t *testing.T) {
+	_, err := getEncoder("xml", EncoderKeys{})
+	assert.NotNil(t, err)
+	_, err = New(Options{Stdout: true, TdrEncoding: "xml"})
+	assert.NotNil(t, err)
+}
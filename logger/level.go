@@ -0,0 +1,9 @@
+package logger
+
+import "net/http"
+
+// LevelHandler serves the level of l as JSON, GET reads it and PUT {"level":"debug"} changes it.
+// The change applies to the running instance only, e.g. e.Any("/log/level", echo.WrapHandler(logger.LevelHandler(l)))
+func LevelHandler(l Logger) http.Handler {
+	return l.Level()
+}
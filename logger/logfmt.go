@@ -0,0 +1,261 @@
+package logger
+
+import (
+	"encoding/base64"
+	stdjson "encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder encodes entries as key=value pairs, objects and arrays are JSON values
+type logfmtEncoder struct {
+	cfg       zapcore.EncoderConfig
+	buf       *buffer.Buffer
+	namespace string
+}
+
+func newLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{cfg: cfg, buf: logfmtPool.Get()}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get(), namespace: e.namespace}
+	clone.buf.Write(e.buf.Bytes())
+	return clone
+}
+
+func (e *logfmtEncoder) key(key string) {
+	if e.buf.Len() > 0 {
+		e.buf.AppendByte(' ')
+	}
+	e.buf.AppendString(e.namespace + key)
+	e.buf.AppendByte('=')
+}
+
+// needsQuote reports whether the value has to be quoted to stay one logfmt value
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *logfmtEncoder) value(s string) {
+	if needsQuote(s) {
+		e.buf.AppendString(strconv.Quote(s))
+		return
+	}
+	e.buf.AppendString(s)
+}
+
+// json writes v as JSON value, encoding/json like zap json encoder
+func (e *logfmtEncoder) json(key string, v interface{}) error {
+	b, err := stdjson.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.key(key)
+	e.value(string(b))
+	return nil
+}
+
+func (e *logfmtEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, marshaler); err != nil {
+		return err
+	}
+	return e.json(key, m.Fields[key])
+}
+
+func (e *logfmtEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := marshaler.MarshalLogObject(m); err != nil {
+		return err
+	}
+	return e.json(key, m.Fields)
+}
+
+func (e *logfmtEncoder) AddReflected(key string, value interface{}) error {
+	return e.json(key, value)
+}
+
+func (e *logfmtEncoder) OpenNamespace(key string) {
+	e.namespace += key + "."
+}
+
+func (e *logfmtEncoder) AddBinary(key string, value []byte) {
+	e.AddString(key, base64.StdEncoding.EncodeToString(value))
+}
+
+func (e *logfmtEncoder) AddByteString(key string, value []byte) {
+	e.AddString(key, string(value))
+}
+
+func (e *logfmtEncoder) AddBool(key string, value bool) {
+	e.key(key)
+	e.buf.AppendBool(value)
+}
+
+func (e *logfmtEncoder) AddComplex128(key string, value complex128) {
+	e.key(key)
+	e.value(fmt.Sprint(value))
+}
+
+func (e *logfmtEncoder) AddComplex64(key string, value complex64) {
+	e.AddComplex128(key, complex128(value))
+}
+
+func (e *logfmtEncoder) AddDuration(key string, value time.Duration) {
+	if e.cfg.EncodeDuration == nil {
+		e.AddString(key, value.String())
+		return
+	}
+	e.key(key)
+	e.value(encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeDuration(value, enc) }))
+}
+
+func (e *logfmtEncoder) AddFloat64(key string, value float64) {
+	e.key(key)
+	e.buf.AppendFloat(value, 64)
+}
+
+func (e *logfmtEncoder) AddFloat32(key string, value float32) {
+	e.key(key)
+	e.buf.AppendFloat(float64(value), 32)
+}
+
+func (e *logfmtEncoder) AddInt(key string, value int)     { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt32(key string, value int32) { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt16(key string, value int16) { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt8(key string, value int8)   { e.AddInt64(key, int64(value)) }
+
+func (e *logfmtEncoder) AddInt64(key string, value int64) {
+	e.key(key)
+	e.buf.AppendInt(value)
+}
+
+func (e *logfmtEncoder) AddString(key, value string) {
+	e.key(key)
+	e.value(value)
+}
+
+func (e *logfmtEncoder) AddTime(key string, value time.Time) {
+	if e.cfg.EncodeTime == nil {
+		e.AddString(key, value.Format(time.RFC3339Nano))
+		return
+	}
+	e.key(key)
+	e.value(encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeTime(value, enc) }))
+}
+
+func (e *logfmtEncoder) AddUint(key string, value uint)       { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint32(key string, value uint32)   { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint16(key string, value uint16)   { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint8(key string, value uint8)     { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUintptr(key string, value uintptr) { e.AddUint64(key, uint64(value)) }
+
+func (e *logfmtEncoder) AddUint64(key string, value uint64) {
+	e.key(key)
+	e.buf.AppendUint(value)
+}
+
+// EncodeEntry writes time, level, name, caller and message followed by the context and fields
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	line := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get()}
+
+	if e.cfg.TimeKey != "" {
+		line.AddTime(e.cfg.TimeKey, ent.Time)
+	}
+	if e.cfg.LevelKey != "" {
+		line.key(e.cfg.LevelKey)
+		if e.cfg.EncodeLevel == nil {
+			line.value(ent.Level.String())
+		} else {
+			line.value(encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeLevel(ent.Level, enc) }))
+		}
+	}
+	if e.cfg.NameKey != "" && ent.LoggerName != "" {
+		line.AddString(e.cfg.NameKey, ent.LoggerName)
+	}
+	if e.cfg.CallerKey != "" && ent.Caller.Defined {
+		line.key(e.cfg.CallerKey)
+		if e.cfg.EncodeCaller == nil {
+			line.value(ent.Caller.TrimmedPath())
+		} else {
+			line.value(encodeText(func(enc zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeCaller(ent.Caller, enc) }))
+		}
+	}
+	if e.cfg.MessageKey != "" {
+		line.AddString(e.cfg.MessageKey, ent.Message)
+	}
+
+	if e.buf.Len() > 0 {
+		if line.buf.Len() > 0 {
+			line.buf.AppendByte(' ')
+		}
+		line.buf.Write(e.buf.Bytes())
+	}
+	line.namespace = e.namespace
+	for _, field := range fields {
+		field.AddTo(line)
+	}
+	line.namespace = ""
+
+	if e.cfg.StacktraceKey != "" && ent.Stack != "" {
+		line.AddString(e.cfg.StacktraceKey, ent.Stack)
+	}
+	if e.cfg.LineEnding != "" {
+		line.buf.AppendString(e.cfg.LineEnding)
+	} else {
+		line.buf.AppendString(zapcore.DefaultLineEnding)
+	}
+	return line.buf, nil
+}
+
+// encodeText renders the value appended by a zap encoder callback as text
+func encodeText(encode func(zapcore.PrimitiveArrayEncoder)) string {
+	var t textEncoder
+	encode(&t)
+	return strings.Join(t.vals, ",")
+}
+
+// textEncoder collects primitives appended by zap encoder callbacks
+type textEncoder struct {
+	vals []string
+}
+
+func (t *textEncoder) append(s string) { t.vals = append(t.vals, s) }
+
+func (t *textEncoder) AppendBool(v bool)             { t.append(strconv.FormatBool(v)) }
+func (t *textEncoder) AppendByteString(v []byte)     { t.append(string(v)) }
+func (t *textEncoder) AppendComplex128(v complex128) { t.append(fmt.Sprint(v)) }
+func (t *textEncoder) AppendComplex64(v complex64)   { t.append(fmt.Sprint(v)) }
+func (t *textEncoder) AppendFloat64(v float64)       { t.append(strconv.FormatFloat(v, 'f', -1, 64)) }
+func (t *textEncoder) AppendFloat32(v float32) {
+	t.append(strconv.FormatFloat(float64(v), 'f', -1, 32))
+}
+func (t *textEncoder) AppendInt(v int)         { t.append(strconv.Itoa(v)) }
+func (t *textEncoder) AppendInt64(v int64)     { t.append(strconv.FormatInt(v, 10)) }
+func (t *textEncoder) AppendInt32(v int32)     { t.AppendInt64(int64(v)) }
+func (t *textEncoder) AppendInt16(v int16)     { t.AppendInt64(int64(v)) }
+func (t *textEncoder) AppendInt8(v int8)       { t.AppendInt64(int64(v)) }
+func (t *textEncoder) AppendString(v string)   { t.append(v) }
+func (t *textEncoder) AppendUint(v uint)       { t.AppendUint64(uint64(v)) }
+func (t *textEncoder) AppendUint64(v uint64)   { t.append(strconv.FormatUint(v, 10)) }
+func (t *textEncoder) AppendUint32(v uint32)   { t.AppendUint64(uint64(v)) }
+func (t *textEncoder) AppendUint16(v uint16)   { t.AppendUint64(uint64(v)) }
+func (t *textEncoder) AppendUint8(v uint8)     { t.AppendUint64(uint64(v)) }
+func (t *textEncoder) AppendUintptr(v uintptr) { t.AppendUint64(uint64(v)) }
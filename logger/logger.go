@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"context"
+	"io"
 	"os"
 	"path"
 	"runtime"
@@ -24,70 +26,159 @@ type Logger interface {
 	Fatal(message string, fields ...zap.Field)
 	Panic(message string, fields ...zap.Field)
 	TDR(tdr LogTdrModel)
+	//Level of the main logger, safe to change while logging
+	Level() zap.AtomicLevel
+	//Redactor configured by Options.Redact, nil when redaction is disabled
+	Redactor() *Redactor
+	//Sync writes buffered lines of main and TDR logs
+	Sync() error
+	//Close syncs and closes the outputs. Outputs are owned by the logger returned by New,
+	//Close of loggers derived with With only syncs
+	Close() error
+	//Dropped number of lines dropped while the async queue was full
+	Dropped() uint64
+
+	//With returns logger adding the fields to every line of the main log
+	With(fields ...zap.Field) Logger
+	//Ctx variants add thread and trace ids carried by ctx, see ContextWithThreadID and ContextWithTrace
+	DebugCtx(ctx context.Context, message string, fields ...zap.Field)
+	InfoCtx(ctx context.Context, message string, fields ...zap.Field)
+	WarnCtx(ctx context.Context, message string, fields ...zap.Field)
+	ErrorCtx(ctx context.Context, message string, fields ...zap.Field)
 }
 
-func New(config Options) Logger {
-	cores := []zapcore.Core{}
+// New creates logger of the options, the error reports invalid options and outputs
+// that can't be opened. Close it on shutdown to write buffered lines.
+func New(config Options) (Logger, error) {
+	level := zap.NewAtomicLevel()
+	if config.Level != "" {
+		if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+			return nil, err
+		}
+	}
 
-	var writer zapcore.WriteSyncer
+	redactor, err := NewRedactor(config.Redact)
+	if err != nil {
+		return nil, err
+	}
+
+	async, err := newAsyncWrapper(config.Async)
+	if err != nil {
+		return nil, err
+	}
 
-	if config.Stdout {
-		writer = zapcore.AddSync(os.Stdout)
+	l := &zapLogger{
+		level:    level,
+		redactor: redactor,
+		async:    async,
+	}
+
+	var combinedCore zapcore.Core
+	if len(config.Sinks) > 0 {
+		core, closers, err := newSinksCore(config.Sinks, false, level, async)
+		if err != nil {
+			return nil, err
+		}
+		combinedCore, l.closers = core, append(l.closers, closers...)
 	} else {
-		rotate, err := rotateLogs.New(
-			config.FileLocation+".%Y%m%d",
-			rotateLogs.WithLinkName(config.FileLocation),
-			rotateLogs.WithMaxAge(config.FileMaxAge*24*time.Hour),
-			rotateLogs.WithRotationTime(time.Hour),
-		)
+		encoder, err := getEncoder(config.Encoding, config.Keys)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		writer = zapcore.AddSync(rotate)
+		writer, closer, err := fileWriter(config.Stdout, config.FileLocation, config.FileMaxAge)
+		if err != nil {
+			return nil, err
+		}
+		writer, closer = async.wrap(writer, closer)
+		combinedCore = zapcore.NewCore(encoder, writer, level)
+		l.closers = append(l.closers, closer)
 	}
 
-	core := zapcore.NewCore(getEncoder(), writer, zapcore.InfoLevel)
-	cores = append(cores, core)
-
-	combinedCore := zapcore.NewTee(cores...)
-
-	logger := zap.New(combinedCore,
-		zap.AddCallerSkip(3),
-		zap.AddCaller(),
-	)
-
 	//logger TDR
-	var tdrWriter zapcore.WriteSyncer
-	if config.Stdout {
-		tdrWriter = zapcore.AddSync(os.Stdout)
+	var tdrCore zapcore.Core
+	if len(config.TdrSinks) > 0 {
+		core, closers, err := newSinksCore(config.TdrSinks, true, zapcore.InfoLevel, async)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		tdrCore, l.closers = core, append(l.closers, closers...)
 	} else {
-		rotateLogsTdr, err := rotateLogs.New(
-			config.FileTdrLocation+".%Y%m%d",
-			rotateLogs.WithLinkName(config.FileTdrLocation),
-			rotateLogs.WithMaxAge(config.FileMaxAge*24*time.Hour),
-			rotateLogs.WithRotationTime(time.Hour),
-		)
+		tdrEncoder, err := getTdrEncoder(config.TdrEncoding, config.TdrKeys)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		writer, closer, err := fileWriter(config.Stdout, config.FileTdrLocation, config.FileMaxAge)
 		if err != nil {
-			panic(err)
+			l.Close()
+			return nil, err
 		}
-		tdrWriter = zapcore.AddSync(rotateLogsTdr)
+		writer, closer = async.wrap(writer, closer)
+		tdrCore = zapcore.NewCore(tdrEncoder, writer, zapcore.InfoLevel)
+		l.closers = append(l.closers, closer)
 	}
 
-	tdrCore := zapcore.NewCore(getTdrEncoder(), tdrWriter, zapcore.InfoLevel)
-	loggerTdr := zap.New(tdrCore,
+	//throttles report suppressed lines on close, before the outputs are closed
+	combinedCore, closer, err := newThrottleCore(combinedCore, config.Throttle)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	l.closers = append([]io.Closer{closer}, l.closers...)
+	tdrCore, closer, err = newThrottleCore(tdrCore, config.TdrThrottle)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	l.closers = append([]io.Closer{closer}, l.closers...)
+
+	l.logger = zap.New(combinedCore,
+		zap.AddCallerSkip(3),
+		zap.AddCaller(),
+	)
+	l.loggerTdr = zap.New(tdrCore,
 		zap.AddCallerSkip(2),
 		zap.AddCaller(),
 	)
+	return l, nil
+}
 
-	return &zapLogger{
-		logger:    logger,
-		loggerTdr: loggerTdr,
+// fileWriter writes to stdout or to a daily rotated file, the file is opened
+// right away so an unwritable location is reported by New
+func fileWriter(stdout bool, location string, maxAge time.Duration) (zapcore.WriteSyncer, io.Closer, error) {
+	if stdout {
+		return stdWriter(os.Stdout), nil, nil
+	}
+
+	//older configs set the number of days
+	if maxAge > 0 && maxAge < time.Microsecond {
+		maxAge *= 24 * time.Hour
 	}
+	rotate, err := rotateLogs.New(
+		location+".%Y%m%d",
+		rotateLogs.WithLinkName(location),
+		rotateLogs.WithMaxAge(maxAge),
+		rotateLogs.WithRotationTime(time.Hour),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = rotate.Write(nil); err != nil {
+		return nil, nil, err
+	}
+	return zapcore.AddSync(rotate), rotate, nil
 }
 
 type zapLogger struct {
 	logger    *zap.Logger
 	loggerTdr *zap.Logger
+	level     zap.AtomicLevel
+	redactor  *Redactor
+	async     *asyncWrapper
+	//closers of the outputs, async writers are closed before their output.
+	//nil in loggers derived with With, outputs are closed by the root only
+	closers []io.Closer
 }
 
 type LogTdrModel struct {
@@ -106,23 +197,6 @@ type LogTdrModel struct {
 	AdditionalData interface{} `json:"addData"`
 }
 
-func getEncoder() zapcore.Encoder {
-	encoderConfig := zap.NewProductionEncoderConfig()
-	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	return zapcore.NewConsoleEncoder(encoderConfig)
-}
-
-func getTdrEncoder() zapcore.Encoder {
-	tdrConfig := zapcore.EncoderConfig{
-		TimeKey:        "time",
-		MessageKey:     "message",
-		EncodeDuration: MillisDurationEncoder,
-		EncodeTime:     TDRLogTimeEncoder,
-		LineEnding:     zapcore.DefaultLineEnding,
-	}
-	return zapcore.NewConsoleEncoder(tdrConfig)
-}
-
 func TDRLogTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format("2006-01-02 15:04:05.999"))
 }
@@ -155,7 +229,80 @@ func (l *zapLogger) Panic(message string, fields ...zap.Field) {
 	l.logger.Panic(message, fields...)
 }
 
+// Nop returns logger discarding everything
+func Nop() Logger {
+	return &zapLogger{
+		logger:    zap.NewNop(),
+		loggerTdr: zap.NewNop(),
+		level:     zap.NewAtomicLevel(),
+	}
+}
+
+func (l *zapLogger) With(fields ...zap.Field) Logger {
+	return &zapLogger{
+		logger:    l.logger.With(fields...),
+		loggerTdr: l.loggerTdr,
+		level:     l.level,
+		redactor:  l.redactor,
+		async:     l.async,
+	}
+}
+
+func (l *zapLogger) DebugCtx(ctx context.Context, message string, fields ...zap.Field) {
+	l.logger.Debug(message, append(contextFields(ctx), fields...)...)
+}
+
+func (l *zapLogger) InfoCtx(ctx context.Context, message string, fields ...zap.Field) {
+	l.logger.Info(message, append(contextFields(ctx), fields...)...)
+}
+
+func (l *zapLogger) WarnCtx(ctx context.Context, message string, fields ...zap.Field) {
+	l.logger.Warn(message, append(contextFields(ctx), fields...)...)
+}
+
+func (l *zapLogger) ErrorCtx(ctx context.Context, message string, fields ...zap.Field) {
+	l.logger.Error(message, append(contextFields(ctx), fields...)...)
+}
+
+func (l *zapLogger) Level() zap.AtomicLevel {
+	return l.level
+}
+
+func (l *zapLogger) Redactor() *Redactor {
+	return l.redactor
+}
+
+func (l *zapLogger) Sync() error {
+	err := l.logger.Sync()
+	if terr := l.loggerTdr.Sync(); err == nil {
+		err = terr
+	}
+	return err
+}
+
+func (l *zapLogger) Close() error {
+	var err error
+	if l.logger != nil && l.loggerTdr != nil {
+		err = l.Sync()
+	}
+	for _, c := range l.closers {
+		if c == nil {
+			continue
+		}
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	l.closers = nil
+	return err
+}
+
+func (l *zapLogger) Dropped() uint64 {
+	return l.async.droppedLines()
+}
+
 func (l *zapLogger) TDR(model LogTdrModel) {
+	r := l.redactor
 	l.loggerTdr.Info(
 		"|",
 		zap.String("xid", model.ThreadID),
@@ -165,12 +312,12 @@ func (l *zapLogger) TDR(model LogTdrModel) {
 		zap.String("app", model.AppName),
 		zap.String("ver", model.AppVersion),
 		zap.String("path", model.Path),
-		zap.Any("header", model.Header),
-		zap.Any("req", toJSON(model.Request)),
-		zap.Any("resp", toJSON(model.Response)),
+		zap.Any("header", r.Redact(model.Header)),
+		zap.Any("req", r.Redact(toJSON(model.Request))),
+		zap.Any("resp", r.Redact(toJSON(model.Response))),
 		zap.String("srcIP", model.SrcIP),
 		zap.String("error", model.Error),
-		zap.Any("addData", toJSON(model.AdditionalData)),
+		zap.Any("addData", r.Redact(toJSON(model.AdditionalData))),
 	)
 }
 
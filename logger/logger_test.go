@@ -1,10 +1,14 @@
 package logger
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
 )
 
 type Coordinate struct {
@@ -73,7 +77,10 @@ func newOptions(fileLocation, fileTdrLocation string, fileMaxAge time.Duration,
 }
 
 func newLogger(options Options) (logger Logger) {
-	logger = New(options)
+	logger, err := New(options)
+	if err != nil {
+		panic(err)
+	}
 	return
 }
 
@@ -193,3 +200,35 @@ func BenchmarkTDRLogger(b *testing.B) {
 		logger.TDR(newTDR(appName, appVersion, ip, srcIP, path, port, respTime, headers, request, response))
 	}
 }
+
+func TestLevel(t *testing.T) {
+	l, err := New(Options{Stdout: true})
+	assert.Nil(t, err)
+	assert.Equal(t, zapcore.InfoLevel, l.Level().Level())
+	assert.False(t, l.Level().Enabled(zapcore.DebugLevel))
+
+	l, err = New(Options{Stdout: true, Level: "debug"})
+	assert.Nil(t, err)
+	assert.True(t, l.Level().Enabled(zapcore.DebugLevel))
+
+	l.Level().SetLevel(zapcore.WarnLevel)
+	assert.False(t, l.Level().Enabled(zapcore.InfoLevel))
+
+	_, err = New(Options{Stdout: true, Level: "verbose"})
+	assert.NotNil(t, err)
+}
+
+func TestLevelHandler(t *testing.T) {
+	l, err := New(Options{Stdout: true})
+	assert.Nil(t, err)
+	h := LevelHandler(l)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/log/level", nil))
+	assert.JSONEq(t, `{"level":"info"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"debug"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, zapcore.DebugLevel, l.Level().Level())
+}
@@ -2,9 +2,147 @@ package logger
 
 import "time"
 
+// Encodings of log lines
+const (
+	EncodingConsole = "console"
+	EncodingJSON    = "json"
+	EncodingLogfmt  = "logfmt"
+)
+
+// EncoderKeys field keys of encoded lines, empty keeps the default and "-" omits the field
+type EncoderKeys struct {
+	Time       string `json:"time"`
+	Level      string `json:"level"`
+	Message    string `json:"message"`
+	Caller     string `json:"caller"`
+	Name       string `json:"name"`
+	Stacktrace string `json:"stacktrace"`
+}
+
+// Sink types
+const (
+	SinkStdout = "stdout"
+	SinkStderr = "stderr"
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+	SinkTCP    = "tcp"
+	SinkHTTP   = "http"
+)
+
+// SinkOptions output of the main or TDR log
+type SinkOptions struct {
+	Type string `json:"type"`
+	//Level minimum level, default follows Options.Level for the main log and is info for TDR
+	Level string `json:"level"`
+	//Encoding and Keys as Options.Encoding and Options.Keys
+	Encoding string      `json:"encoding"`
+	Keys     EncoderKeys `json:"keys"`
+
+	//Path of the file sink
+	Path string `json:"path"`
+	//MaxSizeMB rotates the file before it grows beyond, 0 disables size rotation
+	MaxSizeMB int `json:"maxSizeMB"`
+	//RotationTime rotates the file every period aligned to UTC, e.g. 24h, 0 disables time rotation
+	RotationTime time.Duration `json:"rotationTime"`
+	//MaxAge and MaxBackups remove older rotated files, 0 keeps them
+	MaxAge     time.Duration `json:"maxAge"`
+	MaxBackups int           `json:"maxBackups"`
+	//Compress gzips rotated files
+	Compress bool `json:"compress"`
+
+	//Address of the syslog unix socket (local syslog when empty), host:port of the tcp sink
+	//or URL of the http sink receiving newline delimited lines
+	Address string `json:"address"`
+	//Tag of syslog messages, default is the program name
+	Tag string `json:"tag"`
+	//Timeout of tcp and http writes, default is 5s
+	Timeout time.Duration `json:"timeout"`
+	//FlushInterval and BatchSize in bytes of http batches, default are 1s and 1MB
+	FlushInterval time.Duration `json:"flushInterval"`
+	BatchSize     int           `json:"batchSize"`
+}
+
+// AsyncOptions buffers lines in a bounded queue written in background,
+// call Logger.Close on shutdown to write the queued lines
+type AsyncOptions struct {
+	Enabled bool `json:"enabled"`
+	//QueueSize lines queued per output, default is 8192
+	QueueSize int `json:"queueSize"`
+	//FlushInterval of buffered lines, default is 1s
+	FlushInterval time.Duration `json:"flushInterval"`
+	//Overflow policy when the queue is full: drop (default) or block
+	Overflow string `json:"overflow"`
+}
+
+// DefaultSamplingKeyFields tell apart lines sharing the "|" message of session and cache logs
+var DefaultSamplingKeyFields = []string{"_app_tag"}
+
+// SamplingOptions logs the First lines of the same level, message and key fields per Interval,
+// then every Thereafter-th line
+type SamplingOptions struct {
+	//First lines per interval, sampling is disabled when 0
+	First int `json:"first"`
+	//Thereafter 0 suppresses the remaining lines of the interval
+	Thereafter int `json:"thereafter"`
+	//Interval default is 1s
+	Interval time.Duration `json:"interval"`
+	//KeyFields fields telling lines of the same message apart, nil uses DefaultSamplingKeyFields.
+	//Fields added by With aren't seen, lines differing only by them are sampled together
+	KeyFields []string `json:"keyFields"`
+}
+
+// RateLimitOptions limits lines of the same level, message and key fields to Limit per Interval
+type RateLimitOptions struct {
+	//Limit lines per interval, rate limiting is disabled when 0
+	Limit int `json:"limit"`
+	//Interval default is 1s
+	Interval time.Duration `json:"interval"`
+	//Level minimum level limited, default is error
+	Level string `json:"level"`
+	//KeyFields fields telling lines of the same message apart, e.g. caller
+	KeyFields []string `json:"keyFields"`
+}
+
+// ThrottleOptions suppresses repeated lines, panic and fatal lines are never suppressed
+type ThrottleOptions struct {
+	Sampling  SamplingOptions  `json:"sampling"`
+	RateLimit RateLimitOptions `json:"rateLimit"`
+	//ReportInterval of warn lines counting suppressed lines per message, default is 1m
+	ReportInterval time.Duration `json:"reportInterval"`
+}
+
 type Options struct {
-	FileLocation    string        `json:"fileLocation"`
-	FileTdrLocation string        `json:"fileTdrLocation"`
-	FileMaxAge      time.Duration `json:"fileMaxAge"`
-	Stdout          bool          `json:"stdout"`
+	FileLocation    string `json:"fileLocation"`
+	FileTdrLocation string `json:"fileTdrLocation"`
+	//FileMaxAge max age of rotated files, e.g. 7 * 24 * time.Hour. A bare number of days
+	//of older configs (below a microsecond) is still understood as days
+	FileMaxAge time.Duration `json:"fileMaxAge"`
+	Stdout     bool          `json:"stdout"`
+
+	//Sinks of the main log, replace Stdout and FileLocation when set
+	Sinks []SinkOptions `json:"sinks"`
+	//TdrSinks of the TDR log, replace Stdout and FileTdrLocation when set
+	TdrSinks []SinkOptions `json:"tdrSinks"`
+
+	//Level minimum level of the main logger: debug, info, warn, error, dpanic, panic or fatal.
+	//Default is info, it can be changed at runtime with Logger.Level
+	Level string `json:"level"`
+
+	//Encoding of the main log: console (default), json or logfmt
+	Encoding string      `json:"encoding"`
+	Keys     EncoderKeys `json:"keys"`
+	//TdrEncoding of the TDR log: console (default), json or logfmt
+	TdrEncoding string      `json:"tdrEncoding"`
+	TdrKeys     EncoderKeys `json:"tdrKeys"`
+
+	//Throttle and TdrThrottle suppress repeated lines of the main and TDR logs
+	Throttle    ThrottleOptions `json:"throttle"`
+	TdrThrottle ThrottleOptions `json:"tdrThrottle"`
+
+	//Async writes main and TDR logs in background, syslog sinks are still written synchronously
+	Async AsyncOptions `json:"async"`
+
+	//Redact masks sensitive data of TDR header, request, response and additional data,
+	//DefaultRedactRules apply unless rules are set or redaction is disabled
+	Redact RedactOptions `json:"redact"`
 }
@@ -0,0 +1,337 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	stdjson "encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask strategy of a redaction rule
+type Mask string
+
+const (
+	//MaskFull replaces the value with ****, default strategy
+	MaskFull Mask = "full"
+	//MaskPartial keeps the last characters, at most 4 and a quarter of the value,
+	//emails keep the first character and the domain
+	MaskPartial Mask = "partial"
+	//MaskHash replaces the value with a sha256 digest, HMAC when RedactOptions.HashKey is set,
+	//so equal values can still be correlated
+	MaskHash Mask = "hash"
+)
+
+const maskedValue = "****"
+
+// RedactRule masks values matched by field names, JSON paths or a regular expression
+type RedactRule struct {
+	//Fields key names masked at any depth, case insensitive, - and _ are ignored
+	Fields []string `json:"fields"`
+	//Paths dot separated JSON paths from the root of the value, e.g. data.card.number,
+	//* matches any key or array index
+	Paths []string `json:"paths"`
+	//Pattern regular expression masked in every string value
+	Pattern string `json:"pattern"`
+	//Luhn masks matches of Pattern only when their digits pass the Luhn checksum, e.g. card numbers
+	Luhn bool `json:"luhn"`
+	Mask Mask `json:"mask"`
+}
+
+// RedactOptions configures redaction of TDR and session logs
+type RedactOptions struct {
+	//Disabled turns redaction off
+	Disabled bool `json:"disabled"`
+	//Rules applied in order, DefaultRedactRules when nil
+	Rules   []RedactRule `json:"rules"`
+	HashKey string       `json:"hashKey"`
+}
+
+// DefaultRedactRules masks credentials, card numbers, emails and phone numbers
+var DefaultRedactRules = []RedactRule{
+	{
+		Fields: []string{"password", "passwd", "pin", "otp", "cvv", "cvc", "secret", "token",
+			"accessToken", "refreshToken", "apiKey", "authorization", "cookie", "setCookie"},
+		Mask: MaskFull,
+	},
+	//PAN of 13-19 digits, optionally grouped with spaces or dashes
+	{Pattern: `\b[2-6](?:\d[ -]?){11,17}\d\b`, Luhn: true, Mask: MaskPartial},
+	{Pattern: `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`, Mask: MaskPartial},
+	//international numbers and Indonesian mobile numbers
+	{Pattern: `\+\d{8,15}\b|\b(?:62|0)8\d{7,11}\b`, Mask: MaskPartial},
+}
+
+type redactPath struct {
+	segs []string
+	mask Mask
+}
+
+type redactPattern struct {
+	re   *regexp.Regexp
+	luhn bool
+	mask Mask
+}
+
+// Redactor masks sensitive data of log values, nil Redactor leaves values unchanged
+type Redactor struct {
+	fields   map[string]Mask
+	paths    []redactPath
+	patterns []redactPattern
+	hashKey  []byte
+}
+
+// NewRedactor compiles the rules of opts, nil when redaction is disabled
+func NewRedactor(opts RedactOptions) (*Redactor, error) {
+	if opts.Disabled {
+		return nil, nil
+	}
+	rules := opts.Rules
+	if rules == nil {
+		rules = DefaultRedactRules
+	}
+
+	r := &Redactor{fields: map[string]Mask{}, hashKey: []byte(opts.HashKey)}
+	for _, rule := range rules {
+		mask := rule.Mask
+		switch mask {
+		case "":
+			mask = MaskFull
+		case MaskFull, MaskPartial, MaskHash:
+		default:
+			return nil, fmt.Errorf("unknown redact mask %q", rule.Mask)
+		}
+
+		for _, field := range rule.Fields {
+			if _, ok := r.fields[normalizeKey(field)]; !ok {
+				r.fields[normalizeKey(field)] = mask
+			}
+		}
+		for _, path := range rule.Paths {
+			r.paths = append(r.paths, redactPath{segs: strings.Split(path, "."), mask: mask})
+		}
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, err
+			}
+			r.patterns = append(r.patterns, redactPattern{re: re, luhn: rule.Luhn, mask: mask})
+		}
+	}
+	return r, nil
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+}
+
+// Redact returns v with sensitive data masked, v itself is never modified.
+// Values other than strings are redacted on their JSON representation, patterns apply to
+// strings only.
+func (r *Redactor) Redact(v interface{}) interface{} {
+	if r == nil || v == nil {
+		return v
+	}
+	if s, ok := v.(string); ok {
+		return r.RedactString(s)
+	}
+
+	b, err := stdjson.Marshal(v)
+	if err != nil {
+		return v
+	}
+	generic, err := decodeJSON(b)
+	if err != nil {
+		return v
+	}
+	if redacted, changed := r.walk(generic, nil); changed {
+		return redacted
+	}
+	return v
+}
+
+// RedactString masks s, JSON documents are redacted by fields and paths too
+func (r *Redactor) RedactString(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if generic, err := decodeJSON([]byte(trimmed)); err == nil {
+			redacted, changed := r.walk(generic, nil)
+			if !changed {
+				return s
+			}
+			if b, err := encodeJSON(redacted); err == nil {
+				return b
+			}
+		}
+	}
+	return r.redactPatterns(s)
+}
+
+func decodeJSON(b []byte) (v interface{}, err error) {
+	dec := stdjson.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err = dec.Decode(&v)
+	return
+}
+
+func encodeJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := stdjson.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// walk redacts decoded JSON in place and reports whether anything was masked
+func (r *Redactor) walk(v interface{}, path []string) (interface{}, bool) {
+	changed := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, val := range t {
+			p := append(path[:len(path):len(path)], key)
+			if mask, ok := r.match(key, p); ok {
+				t[key], changed = r.mask(val, mask), true
+				continue
+			}
+			var c bool
+			if t[key], c = r.walk(val, p); c {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for i, val := range t {
+			p := append(path[:len(path):len(path)], strconv.Itoa(i))
+			if mask, ok := r.matchPath(p); ok {
+				t[i], changed = r.mask(val, mask), true
+				continue
+			}
+			var c bool
+			if t[i], c = r.walk(val, p); c {
+				changed = true
+			}
+		}
+	case string:
+		if s := r.redactPatterns(t); s != t {
+			return s, true
+		}
+	}
+	return v, changed
+}
+
+func (r *Redactor) match(key string, path []string) (Mask, bool) {
+	if mask, ok := r.fields[normalizeKey(key)]; ok {
+		return mask, true
+	}
+	return r.matchPath(path)
+}
+
+func (r *Redactor) matchPath(path []string) (Mask, bool) {
+	for _, p := range r.paths {
+		if len(p.segs) != len(path) {
+			continue
+		}
+		matched := true
+		for i, seg := range p.segs {
+			if seg != "*" && !strings.EqualFold(seg, path[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return p.mask, true
+		}
+	}
+	return "", false
+}
+
+// mask masks every leaf of v
+func (r *Redactor) mask(v interface{}, mask Mask) interface{} {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		for key, val := range t {
+			t[key] = r.mask(val, mask)
+		}
+		return t
+	case []interface{}:
+		for i, val := range t {
+			t[i] = r.mask(val, mask)
+		}
+		return t
+	case string:
+		return r.maskString(t, mask)
+	}
+	return r.maskString(fmt.Sprint(v), mask)
+}
+
+func (r *Redactor) redactPatterns(s string) string {
+	for _, p := range r.patterns {
+		p := p
+		s = p.re.ReplaceAllStringFunc(s, func(m string) string {
+			if p.luhn && !luhnValid(m) {
+				return m
+			}
+			return r.maskString(m, p.mask)
+		})
+	}
+	return s
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum, other characters are ignored
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+func (r *Redactor) maskString(s string, mask Mask) string {
+	switch mask {
+	case MaskPartial:
+		if at := strings.LastIndexByte(s, '@'); at > 0 {
+			first, _ := utf8.DecodeRuneInString(s)
+			return string(first) + maskedValue + s[at:]
+		}
+		n := utf8.RuneCountInString(s)
+		keep := n / 4
+		if keep > 4 {
+			keep = 4
+		}
+		runes := []rune(s)
+		return strings.Repeat("*", n-keep) + string(runes[n-keep:])
+	case MaskHash:
+		var sum []byte
+		if len(r.hashKey) > 0 {
+			h := hmac.New(sha256.New, r.hashKey)
+			h.Write([]byte(s))
+			sum = h.Sum(nil)
+		} else {
+			digest := sha256.Sum256([]byte(s))
+			sum = digest[:]
+		}
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return maskedValue
+}
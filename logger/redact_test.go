@@ -0,0 +1,117 @@
+package logger
+
+import (
+	stdjson "encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactFields(t *testing.T) {
+	r, err := NewRedactor(RedactOptions{})
+	assert.Nil(t, err)
+
+	type login struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		OTP      int    `json:"otp"`
+	}
+	assert.Equal(t, map[string]interface{}{
+		"username": "bias",
+		"password": "****",
+		"otp":      "****",
+	}, r.Redact(login{Username: "bias", Password: "s3cret", OTP: 123456}))
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer abc")
+	header.Set("X-Request-Id", "42")
+	assert.Equal(t, map[string]interface{}{
+		"Authorization": []interface{}{"****"},
+		"X-Request-Id":  []interface{}{"42"},
+	}, r.Redact(header))
+
+	//unchanged values are returned as is
+	unchanged := login{Username: "bias"}
+	unchanged.Password = ""
+	r2, _ := NewRedactor(RedactOptions{Rules: []RedactRule{{Fields: []string{"pin"}}}})
+	assert.Equal(t, unchanged, r2.Redact(unchanged))
+}
+
+func TestRedactString(t *testing.T) {
+	r, err := NewRedactor(RedactOptions{})
+	assert.Nil(t, err)
+
+	assert.Equal(t, `{"data":{"pin":"****"},"success":true}`,
+		r.RedactString(`{"success": true, "data": {"pin": "1234"}}`))
+	assert.Equal(t, `{"success": true}`, r.RedactString(`{"success": true}`))
+
+	assert.Equal(t, "card ************1111 paid", r.RedactString("card 4111111111111111 paid"))
+	assert.Equal(t, "card ***************1111 paid", r.RedactString("card 4111 1111 1111 1111 paid"))
+	assert.Equal(t, "mail b****@example.com", r.RedactString("mail bias@example.com"))
+	assert.Equal(t, "call *********890", r.RedactString("call 081234567890"))
+	assert.Equal(t, "order 12345 at 2026-10-18", r.RedactString("order 12345 at 2026-10-18"))
+	//digits failing the Luhn checksum aren't card numbers
+	assert.Equal(t, "ref 4111111111111112 paid", r.RedactString("ref 4111111111111112 paid"))
+	//patterns don't apply to JSON numbers
+	assert.Equal(t, `{"amount": 628123456789}`, r.RedactString(`{"amount": 628123456789}`))
+
+	var nilRedactor *Redactor
+	assert.Equal(t, "4111111111111111", nilRedactor.RedactString("4111111111111111"))
+}
+
+func TestRedactPathsAndMasks(t *testing.T) {
+	r, err := NewRedactor(RedactOptions{
+		HashKey: "key",
+		Rules: []RedactRule{
+			{Paths: []string{"data.card.number"}, Mask: MaskPartial},
+			{Paths: []string{"items.*.email"}, Mask: MaskHash},
+		},
+	})
+	assert.Nil(t, err)
+
+	out := r.Redact(map[string]interface{}{
+		"number": "4111111111111111",
+		"data":   map[string]interface{}{"card": map[string]interface{}{"number": "4111111111111111"}},
+		"items":  []interface{}{map[string]interface{}{"email": "bias@example.com"}},
+	}).(map[string]interface{})
+
+	assert.Equal(t, "4111111111111111", out["number"])
+	assert.Equal(t, "************1111", out["data"].(map[string]interface{})["card"].(map[string]interface{})["number"])
+	hashed := out["items"].([]interface{})[0].(map[string]interface{})["email"].(string)
+	assert.Regexp(t, "^sha256:[0-9a-f]{16}$", hashed)
+	assert.Equal(t, hashed, r.maskString("bias@example.com", MaskHash))
+
+	_, err = NewRedactor(RedactOptions{Rules: []RedactRule{{Fields: []string{"pin"}, Mask: "blur"}}})
+	assert.NotNil(t, err)
+	_, err = NewRedactor(RedactOptions{Rules: []RedactRule{{Pattern: "("}}})
+	assert.NotNil(t, err)
+
+	r, err = NewRedactor(RedactOptions{Disabled: true})
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+}
+
+func TestTDRRedacted(t *testing.T) {
+	enc, err := getTdrEncoder(EncodingJSON, EncoderKeys{})
+	assert.Nil(t, err)
+	tdr, buf := newBufferLogger(enc)
+	redactor, err := NewRedactor(RedactOptions{})
+	assert.Nil(t, err)
+
+	l := &zapLogger{loggerTdr: tdr, redactor: redactor}
+	l.TDR(LogTdrModel{
+		Header:         map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+		Request:        `{"username": "bias", "password": "s3cret"}`,
+		Response:       "otp sent to 081234567890",
+		AdditionalData: map[string]interface{}{"card": "4111111111111111"},
+	})
+
+	var line map[string]interface{}
+	assert.Nil(t, stdjson.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, map[string]interface{}{"Authorization": "****"}, line["header"])
+	assert.Equal(t, map[string]interface{}{"username": "bias", "password": "****"}, line["req"])
+	assert.Equal(t, "otp sent to *********890", line["resp"])
+	assert.Equal(t, map[string]interface{}{"card": "************1111"}, line["addData"])
+	assert.NotContains(t, buf.String(), "s3cret")
+}
@@ -0,0 +1,227 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat suffix of rotated files, sorts in rotation order
+const backupTimeFormat = "20060102T150405.000000000"
+
+// rotateFile writes to path rotating it by size and time, rotated files are
+// compressed and pruned in background
+type rotateFile struct {
+	path       string
+	maxSize    int64
+	every      time.Duration
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	now        func() time.Time
+
+	mu     sync.Mutex
+	closed bool
+	file   *os.File
+	size   int64
+	period time.Time
+
+	//background serializes compression and pruning of rotated files
+	background sync.Mutex
+	wg         sync.WaitGroup
+}
+
+func newRotateFile(s SinkOptions) (*rotateFile, error) {
+	if s.Path == "" {
+		return nil, errors.New("file sink without path")
+	}
+	r := &rotateFile{
+		path:       s.Path,
+		maxSize:    int64(s.MaxSizeMB) * 1024 * 1024,
+		every:      s.RotationTime,
+		maxAge:     s.MaxAge,
+		maxBackups: s.MaxBackups,
+		compress:   s.Compress,
+		now:        time.Now,
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return nil, err
+	}
+	return r, r.open()
+}
+
+func (r *rotateFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file, r.size = file, info.Size()
+	//a file left by the previous run belongs to the period it was written in
+	r.period = r.periodOf(r.now())
+	if r.size > 0 {
+		r.period = r.periodOf(info.ModTime())
+	}
+	return nil
+}
+
+func (r *rotateFile) periodOf(t time.Time) time.Time {
+	if r.every <= 0 {
+		return time.Time{}
+	}
+	return t.Truncate(r.every)
+}
+
+func (r *rotateFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	sizeExceeded := r.file != nil && r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize
+	if sizeExceeded || (r.file != nil && r.periodOf(r.now()).After(r.period)) {
+		if err := r.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v rotate log %s: %v\n", time.Now(), r.path, err)
+		}
+	}
+	//the file is reopened when rotating or a previous open failed
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the file and opens a new one, when renaming fails the current file
+// is reopened and rotation is retried after another period or maxSize
+func (r *rotateFile) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		return err
+	}
+
+	backup := r.path + "." + r.now().Format(backupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil {
+		if oerr := r.open(); oerr == nil {
+			r.size, r.period = 0, r.periodOf(r.now())
+		}
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.period = r.periodOf(r.now())
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.background.Lock()
+		defer r.background.Unlock()
+
+		if r.compress {
+			if err := compressFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "%v compress log %s: %v\n", time.Now(), backup, err)
+			}
+		}
+		r.prune()
+	}()
+	return nil
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
+
+// backups returns rotated files, newest first
+func (r *rotateFile) backups() []string {
+	matches, _ := filepath.Glob(r.path + ".*")
+	var backups []string
+	for _, m := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(m, r.path+"."), ".gz")
+		if _, err := time.Parse(backupTimeFormat, suffix); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups
+}
+
+func (r *rotateFile) prune() {
+	if r.maxBackups <= 0 && r.maxAge <= 0 {
+		return
+	}
+	for i, backup := range r.backups() {
+		remove := r.maxBackups > 0 && i >= r.maxBackups
+		if !remove && r.maxAge > 0 {
+			if info, err := os.Stat(backup); err == nil {
+				remove = r.now().Sub(info.ModTime()) > r.maxAge
+			}
+		}
+		if remove {
+			os.Remove(backup)
+		}
+	}
+}
+
+func (r *rotateFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Sync()
+}
+
+// Close closes the file and waits for compression of rotated files
+func (r *rotateFile) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return err
+}
@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRotateFile(t *testing.T, s SinkOptions, now *time.Time) *rotateFile {
+	r, err := newRotateFile(s)
+	assert.Nil(t, err)
+	r.now = func() time.Time { return *now }
+	r.period = r.periodOf(*now)
+	return r
+}
+
+func TestRotateFileSize(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "app.log")
+	r := newTestRotateFile(t, SinkOptions{Path: path, MaxBackups: 2}, &now)
+	r.maxSize = 10
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := r.Write([]byte(line))
+		assert.Nil(t, err)
+		now = now.Add(time.Millisecond)
+	}
+	assert.Nil(t, r.Close())
+
+	current, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "fourth\n", string(current))
+	backups := r.backups()
+	assert.Len(t, backups, 2)
+	previous, _ := ioutil.ReadFile(backups[0])
+	assert.Equal(t, "third\n", string(previous))
+
+	_, err := r.Write([]byte("closed\n"))
+	assert.Equal(t, os.ErrClosed, err)
+}
+
+func TestRotateFileTimeAndCompress(t *testing.T) {
+	now := time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "logs", "tdr.log")
+	r := newTestRotateFile(t, SinkOptions{Path: path, RotationTime: 24 * time.Hour, Compress: true}, &now)
+
+	r.Write([]byte("yesterday\n"))
+	now = now.Add(30 * time.Second)
+	r.Write([]byte("still yesterday\n"))
+	now = now.Add(time.Minute)
+	r.Write([]byte("today\n"))
+	assert.Nil(t, r.Close())
+
+	backups := r.backups()
+	assert.Len(t, backups, 1)
+	assert.Equal(t, path+".20261019T000030.000000000.gz", backups[0])
+
+	f, err := os.Open(backups[0])
+	assert.Nil(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	previous, _ := ioutil.ReadAll(zr)
+	assert.Equal(t, "yesterday\nstill yesterday\n", string(previous))
+
+	current, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "today\n", string(current))
+}
+
+func TestRotateFileMaxAge(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "app.log")
+	old := path + "." + now.Add(-48*time.Hour).Format(backupTimeFormat)
+	assert.Nil(t, ioutil.WriteFile(old, []byte("old\n"), 0644))
+	assert.Nil(t, os.Chtimes(old, now.Add(-48*time.Hour), now.Add(-48*time.Hour)))
+
+	r := newTestRotateFile(t, SinkOptions{Path: path, MaxAge: 24 * time.Hour}, &now)
+	r.maxSize = 1
+	r.Write([]byte("a\n"))
+	r.Write([]byte("b\n"))
+	assert.Nil(t, r.Close())
+
+	backups := r.backups()
+	assert.Len(t, backups, 1)
+	assert.NotEqual(t, old, backups[0])
+}
+
+func TestRotateFileRenameFailure(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "app.log")
+	r := newTestRotateFile(t, SinkOptions{Path: path}, &now)
+	r.maxSize = 10
+
+	//a non empty directory at the backup name makes renaming fail
+	backup := path + "." + now.Format(backupTimeFormat)
+	assert.Nil(t, os.MkdirAll(filepath.Join(backup, "dir"), 0755))
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		_, err := r.Write([]byte(line))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, r.Close())
+
+	current, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "first\nsecond\nthird\n", string(current))
+}
@@ -0,0 +1,384 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newSinksCore tees cores of the sinks, closers release files and connections
+func newSinksCore(sinks []SinkOptions, tdr bool, level zapcore.LevelEnabler, async *asyncWrapper) (zapcore.Core, []io.Closer, error) {
+	var (
+		cores   []zapcore.Core
+		closers []io.Closer
+	)
+	for _, s := range sinks {
+		core, closer, err := newSinkCore(s, tdr, level, async)
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+			return nil, nil, fmt.Errorf("log sink %s: %v", s.Type, err)
+		}
+		cores = append(cores, core)
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
+	return zapcore.NewTee(cores...), closers, nil
+}
+
+func newSinkCore(s SinkOptions, tdr bool, level zapcore.LevelEnabler, async *asyncWrapper) (zapcore.Core, io.Closer, error) {
+	if s.Level != "" {
+		l := zap.NewAtomicLevel()
+		if err := l.UnmarshalText([]byte(s.Level)); err != nil {
+			return nil, nil, err
+		}
+		level = l
+	}
+
+	var (
+		enc zapcore.Encoder
+		err error
+	)
+	if tdr {
+		enc, err = getTdrEncoder(s.Encoding, s.Keys)
+	} else {
+		enc, err = getEncoder(s.Encoding, s.Keys)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		ws     zapcore.WriteSyncer
+		closer io.Closer
+	)
+	switch s.Type {
+	case SinkStdout:
+		ws = stdWriter(os.Stdout)
+	case SinkStderr:
+		ws = stdWriter(os.Stderr)
+	case SinkFile:
+		file, err := newRotateFile(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		ws, closer = file, file
+	case SinkSyslog:
+		return newSyslogCore(s, enc, level)
+	case SinkTCP:
+		w := newTCPWriter(s)
+		ws, closer = w, w
+	case SinkHTTP:
+		w := newHTTPWriter(s)
+		ws, closer = w, w
+	default:
+		return nil, nil, fmt.Errorf("unknown log sink %q", s.Type)
+	}
+
+	ws, closer = async.wrap(ws, closer)
+	return zapcore.NewCore(enc, ws, level), closer, nil
+}
+
+// stdWriter writes to stdout or stderr, they aren't synced as it fails on pipes and terminals
+func stdWriter(f *os.File) zapcore.WriteSyncer {
+	return zapcore.Lock(zapcore.AddSync(struct{ io.Writer }{f}))
+}
+
+func timeoutOrDefault(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 5 * time.Second
+	}
+	return d
+}
+
+// maxPending bytes kept by tcp and http sinks while the collector is unavailable,
+// later lines are dropped
+const maxPending = 4 * 1024 * 1024
+
+// tcpWriter ships lines to a log collector. Writes never dial, lines written while
+// disconnected are kept up to maxPending and sent once the background dialer reconnects.
+type tcpWriter struct {
+	addr    string
+	timeout time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	pending bytes.Buffer
+	dropped int
+
+	redial    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newTCPWriter(s SinkOptions) *tcpWriter {
+	w := &tcpWriter{
+		addr:    s.Address,
+		timeout: timeoutOrDefault(s.Timeout),
+		redial:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.loop()
+	w.reconnect()
+	return w
+}
+
+// reconnect wakes the dialer
+func (w *tcpWriter) reconnect() {
+	select {
+	case w.redial <- struct{}{}:
+	default:
+	}
+}
+
+func (w *tcpWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+		if _, err := w.conn.Write(p); err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+		w.reconnect()
+	}
+
+	if w.pending.Len()+len(p) > maxPending {
+		w.dropped++
+		return len(p), nil
+	}
+	w.pending.Write(p)
+	return len(p), nil
+}
+
+// loop dials the collector when woken, backing off from 100ms up to 30s
+func (w *tcpWriter) loop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.redial:
+		}
+
+		backoff := 100 * time.Millisecond
+		for !w.dial() {
+			select {
+			case <-w.done:
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+		}
+	}
+}
+
+// dial connects and sends the pending lines, reporting whether it succeeded
+func (w *tcpWriter) dial() bool {
+	conn, err := net.DialTimeout("tcp", w.addr, w.timeout)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending.Len() > 0 {
+		conn.SetWriteDeadline(time.Now().Add(w.timeout))
+		if _, err = conn.Write(w.pending.Bytes()); err != nil {
+			conn.Close()
+			return false
+		}
+		w.pending.Reset()
+	}
+	if w.dropped > 0 {
+		fmt.Fprintf(os.Stderr, "%v ship log: %d lines dropped while %s was unavailable\n", time.Now(), w.dropped, w.addr)
+		w.dropped = 0
+	}
+	w.conn = conn
+	return true
+}
+
+func (w *tcpWriter) Sync() error {
+	return nil
+}
+
+// Close stops the dialer, makes one last attempt to send the pending lines
+// and closes the connection
+func (w *tcpWriter) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+	})
+
+	w.mu.Lock()
+	flush := w.conn == nil && w.pending.Len() > 0
+	w.mu.Unlock()
+	lost := flush && !w.dial()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if lost {
+		err = fmt.Errorf("%d bytes of lines lost, %s is unavailable", w.pending.Len(), w.addr)
+		w.pending.Reset()
+	}
+	if w.conn != nil {
+		if cerr := w.conn.Close(); err == nil {
+			err = cerr
+		}
+		w.conn = nil
+	}
+	return err
+}
+
+// httpWriter posts batches of newline delimited lines to a log shipper from a background
+// loop, writes only buffer lines. Lines of failed posts are kept, up to maxPending
+// while the shipper is unavailable, and posted again with the next batch.
+type httpWriter struct {
+	url    string
+	client *http.Client
+	batch  int
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	dropped int
+
+	//send keeps batches in order between the loop and Sync
+	send      sync.Mutex
+	full      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newHTTPWriter(s SinkOptions) *httpWriter {
+	w := &httpWriter{
+		url:    s.Address,
+		client: &http.Client{Timeout: timeoutOrDefault(s.Timeout)},
+		batch:  s.BatchSize,
+		full:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	if w.batch <= 0 {
+		w.batch = 1024 * 1024
+	}
+	interval := s.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	w.wg.Add(1)
+	go w.loop(interval)
+	return w
+}
+
+func (w *httpWriter) loop(interval time.Duration) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		case <-w.full:
+		}
+		if err := w.Sync(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v ship log: %v\n", time.Now(), err)
+		}
+	}
+}
+
+func (w *httpWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len()+len(p) > maxPending {
+		w.dropped++
+		return len(p), nil
+	}
+	w.buf.Write(p)
+	if w.buf.Len() >= w.batch {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Sync posts the buffered lines, they are kept for the next Sync when the shipper fails
+func (w *httpWriter) Sync() error {
+	w.send.Lock()
+	defer w.send.Unlock()
+
+	w.mu.Lock()
+	body := append([]byte(nil), w.buf.Bytes()...)
+	dropped := w.dropped
+	w.buf.Reset()
+	w.dropped = 0
+	w.mu.Unlock()
+
+	var err error
+	if dropped > 0 {
+		err = fmt.Errorf("%d lines dropped while %s was unavailable", dropped, w.url)
+	}
+	if len(body) == 0 {
+		return err
+	}
+
+	resp, perr := w.client.Post(w.url, "application/x-ndjson", bytes.NewReader(body))
+	if perr != nil {
+		w.requeue(body)
+		return perr
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		w.requeue(body)
+		return fmt.Errorf("log shipper %s responded %s", w.url, resp.Status)
+	}
+	return err
+}
+
+// requeue puts lines of a failed post back in front of the buffer,
+// they are dropped when it would exceed maxPending
+func (w *httpWriter) requeue(body []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len()+len(body) > maxPending {
+		w.dropped += bytes.Count(body, []byte("\n"))
+		return
+	}
+	rest := append([]byte(nil), w.buf.Bytes()...)
+	w.buf.Reset()
+	w.buf.Write(body)
+	w.buf.Write(rest)
+}
+
+// Close stops the background loop and posts the remaining lines
+func (w *httpWriter) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+	})
+	return w.Sync()
+}
@@ -0,0 +1,74 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// syslogCore writes entries to syslog with the priority of their level
+type syslogCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	w   *syslog.Writer
+}
+
+func newSyslogCore(s SinkOptions, enc zapcore.Encoder, level zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
+	var (
+		w   *syslog.Writer
+		err error
+	)
+	if s.Address == "" {
+		w, err = syslog.New(syslog.LOG_INFO|syslog.LOG_USER, s.Tag)
+	} else {
+		w, err = syslog.Dial("unixgram", s.Address, syslog.LOG_INFO|syslog.LOG_USER, s.Tag)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &syslogCore{LevelEnabler: level, enc: enc, w: w}, w, nil
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &syslogCore{LevelEnabler: c.LevelEnabler, enc: c.enc.Clone(), w: c.w}
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+	return clone
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	msg := strings.TrimSuffix(buf.String(), "\n")
+	buf.Free()
+
+	switch ent.Level {
+	case zapcore.DebugLevel:
+		return c.w.Debug(msg)
+	case zapcore.InfoLevel:
+		return c.w.Info(msg)
+	case zapcore.WarnLevel:
+		return c.w.Warning(msg)
+	case zapcore.ErrorLevel:
+		return c.w.Err(msg)
+	}
+	return c.w.Crit(msg)
+}
+
+func (c *syslogCore) Sync() error {
+	return nil
+}
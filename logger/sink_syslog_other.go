@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package logger
+
+import (
+	"errors"
+	"io"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func newSyslogCore(s SinkOptions, enc zapcore.Encoder, level zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
+	return nil, nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSinks(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(Options{
+		Level: "debug",
+		Sinks: []SinkOptions{
+			{Type: SinkFile, Path: filepath.Join(dir, "app.log"), Encoding: EncodingLogfmt},
+			{Type: SinkFile, Path: filepath.Join(dir, "error.log"), Level: "error", Encoding: EncodingJSON},
+		},
+		TdrSinks: []SinkOptions{{Type: SinkFile, Path: filepath.Join(dir, "tdr.log"), Encoding: EncodingJSON}},
+	})
+	assert.Nil(t, err)
+	l.Debug("debug line")
+	l.Error("error line")
+	l.TDR(LogTdrModel{ThreadID: "abc"})
+	l.Close()
+
+	app, _ := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Contains(t, string(app), "msg=\"debug line\"")
+	assert.Contains(t, string(app), "msg=\"error line\"")
+
+	errors, _ := ioutil.ReadFile(filepath.Join(dir, "error.log"))
+	assert.NotContains(t, string(errors), "debug line")
+	assert.Contains(t, string(errors), `"msg":"error line"`)
+
+	tdr, _ := ioutil.ReadFile(filepath.Join(dir, "tdr.log"))
+	assert.Contains(t, string(tdr), `"xid":"abc"`)
+
+	_, err = New(Options{Sinks: []SinkOptions{{Type: "kafka"}}})
+	assert.NotNil(t, err)
+	_, err = New(Options{Sinks: []SinkOptions{{Type: SinkFile}}})
+	assert.NotNil(t, err)
+}
+
+func TestTCPSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	l, err := New(Options{
+		Sinks:    []SinkOptions{{Type: SinkTCP, Address: ln.Addr().String(), Encoding: EncodingJSON}},
+		TdrSinks: []SinkOptions{{Type: SinkStdout}},
+	})
+	assert.Nil(t, err)
+	defer l.Close()
+	l.Info("shipped")
+
+	select {
+	case line := <-lines:
+		assert.Contains(t, line, `"msg":"shipped"`)
+	case <-time.After(time.Second):
+		t.Fatal("line not shipped")
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	l, err := New(Options{
+		Sinks:    []SinkOptions{{Type: SinkHTTP, Address: srv.URL, Encoding: EncodingJSON, FlushInterval: time.Hour}},
+		TdrSinks: []SinkOptions{{Type: SinkStdout}},
+	})
+	assert.Nil(t, err)
+	l.Info("first")
+	l.Info("second")
+	l.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, bodies, 1)
+	assert.Equal(t, 2, strings.Count(bodies[0], "\n"))
+	assert.Contains(t, bodies[0], `"msg":"second"`)
+}
+
+func TestSyslogSink(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	l, err := New(Options{
+		Sinks:    []SinkOptions{{Type: SinkSyslog, Address: addr, Tag: "bepkg", Encoding: EncodingLogfmt, Keys: EncoderKeys{Time: "-"}}},
+		TdrSinks: []SinkOptions{{Type: SinkStdout}},
+	})
+	assert.Nil(t, err)
+	defer l.Close()
+	l.Warn("disk almost full")
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	assert.Nil(t, err)
+	//priority of warning in user facility
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "<12>"))
+	assert.Contains(t, string(buf[:n]), "bepkg")
+	assert.Contains(t, string(buf[:n]), `msg="disk almost full"`)
+}
+
+func TestHTTPSinkSlowShipper(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+
+	w := newHTTPWriter(SinkOptions{Address: srv.URL, BatchSize: 1, FlushInterval: time.Hour})
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		_, err := w.Write([]byte("line\n"))
+		assert.Nil(t, err)
+	}
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+
+	close(release)
+	assert.Nil(t, w.Close())
+}
+
+func TestTCPSinkReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	//lines written while the collector is down are kept
+	w := newTCPWriter(SinkOptions{Address: addr, Timeout: 50 * time.Millisecond})
+	defer w.Close()
+	_, err = w.Write([]byte("pending\n"))
+	assert.Nil(t, err)
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("collector port taken: ", err)
+	}
+	defer ln.Close()
+	conn, err := ln.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "pending\n", line)
+}
+
+func TestHTTPSinkRetry(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		calls  int
+		bodies []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		bodies = append(bodies, string(b))
+	}))
+	defer srv.Close()
+
+	w := newHTTPWriter(SinkOptions{Address: srv.URL, FlushInterval: time.Hour})
+	w.Write([]byte("first\n"))
+	assert.NotNil(t, w.Sync())
+
+	//lines of the failed post go out first with the next batch
+	w.Write([]byte("second\n"))
+	assert.Nil(t, w.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"first\nsecond\n"}, bodies)
+}
+
+func TestTCPSinkCloseFlush(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	w := newTCPWriter(SinkOptions{Address: addr, Timeout: 50 * time.Millisecond})
+	w.Write([]byte("last\n"))
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("collector port taken: ", err)
+	}
+	defer ln.Close()
+
+	//pending lines are sent by Close even before the dialer reconnects
+	assert.Nil(t, w.Close())
+	conn, err := ln.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "last\n", line)
+
+	//lines are reported lost when the collector stays down
+	ln.Close()
+	w = newTCPWriter(SinkOptions{Address: addr, Timeout: 50 * time.Millisecond})
+	w.Write([]byte("lost\n"))
+	assert.NotNil(t, w.Close())
+}
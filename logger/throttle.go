@@ -0,0 +1,255 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// stderr reports write errors of lines passed by throttles, as zap does by default
+var stderr = zapcore.Lock(os.Stderr)
+
+// suppressedMessage message of the periodic lines counting suppressed lines
+const suppressedMessage = "log lines suppressed"
+
+type throttleKey struct {
+	level   zapcore.Level
+	message string
+	key     string
+}
+
+type throttleCounter struct {
+	window     time.Time
+	count      uint64
+	suppressed uint64
+}
+
+// throttle samples and rate limits lines of a core, shared by cores derived with With
+type throttle struct {
+	sampling  SamplingOptions
+	rate      RateLimitOptions
+	rateLevel zapcore.Level
+	report    zapcore.Core
+	now       func() time.Time
+
+	mu      sync.Mutex
+	samples map[throttleKey]*throttleCounter
+	limits  map[throttleKey]*throttleCounter
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// newThrottleCore wraps core with the sampling and rate limit of opts, the closer reports the
+// last suppressed counts and stops reporting
+func newThrottleCore(core zapcore.Core, opts ThrottleOptions) (zapcore.Core, io.Closer, error) {
+	if opts.Sampling.First <= 0 && opts.RateLimit.Limit <= 0 {
+		return core, nil, nil
+	}
+
+	t := &throttle{
+		sampling:  opts.Sampling,
+		rate:      opts.RateLimit,
+		rateLevel: zapcore.ErrorLevel,
+		report:    core,
+		now:       time.Now,
+		samples:   map[throttleKey]*throttleCounter{},
+		limits:    map[throttleKey]*throttleCounter{},
+		done:      make(chan struct{}),
+	}
+	if t.rate.Level != "" {
+		if err := t.rateLevel.UnmarshalText([]byte(t.rate.Level)); err != nil {
+			return nil, nil, err
+		}
+	}
+	if t.sampling.Interval <= 0 {
+		t.sampling.Interval = time.Second
+	}
+	if t.sampling.KeyFields == nil {
+		t.sampling.KeyFields = DefaultSamplingKeyFields
+	}
+	if t.rate.Interval <= 0 {
+		t.rate.Interval = time.Second
+	}
+	interval := opts.ReportInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	t.wg.Add(1)
+	go t.loop(interval)
+	return &throttleCore{Core: core, t: t}, t, nil
+}
+
+// allow counts the line in its window and reports whether it passes
+func (t *throttle) allow(counters map[throttleKey]*throttleCounter, key throttleKey, interval time.Duration, pass func(n uint64) bool) bool {
+	now := t.now()
+	c, ok := counters[key]
+	if !ok {
+		c = &throttleCounter{window: now}
+		counters[key] = c
+	}
+	if now.Sub(c.window) >= interval {
+		c.window, c.count = now, 0
+	}
+
+	c.count++
+	if pass(c.count) {
+		return true
+	}
+	c.suppressed++
+	return false
+}
+
+func (t *throttle) check(ent zapcore.Entry, fields []zapcore.Field) bool {
+	//panics and fatal errors are never suppressed
+	if ent.Level > zapcore.ErrorLevel {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if first := uint64(t.sampling.First); first > 0 {
+		thereafter := uint64(t.sampling.Thereafter)
+		key := throttleKey{level: ent.Level, message: ent.Message, key: fieldsKey(t.sampling.KeyFields, fields)}
+		if !t.allow(t.samples, key, t.sampling.Interval, func(n uint64) bool {
+			return n <= first || (thereafter > 0 && (n-first)%thereafter == 0)
+		}) {
+			return false
+		}
+	}
+
+	if limit := uint64(t.rate.Limit); limit > 0 && ent.Level >= t.rateLevel {
+		key := throttleKey{level: ent.Level, message: ent.Message, key: fieldsKey(t.rate.KeyFields, fields)}
+		if !t.allow(t.limits, key, t.rate.Interval, func(n uint64) bool { return n <= limit }) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldsKey joins values of the named fields
+func fieldsKey(names []string, fields []zapcore.Field) string {
+	if len(names) == 0 {
+		return ""
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	values := make([]string, len(names))
+	for i, name := range names {
+		if v, ok := enc.Fields[name]; ok {
+			values[i] = fmt.Sprint(v)
+		}
+	}
+	return strings.Join(values, "|")
+}
+
+func (t *throttle) loop(interval time.Duration) {
+	defer t.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.flush()
+		}
+	}
+}
+
+// flush logs the suppressed counts and forgets counters of past windows
+func (t *throttle) flush() {
+	type suppressed struct {
+		key throttleKey
+		n   uint64
+	}
+	var lines []suppressed
+
+	t.mu.Lock()
+	now := t.now()
+	for _, c := range []struct {
+		counters map[throttleKey]*throttleCounter
+		interval time.Duration
+	}{
+		{t.samples, t.sampling.Interval},
+		{t.limits, t.rate.Interval},
+	} {
+		for key, counter := range c.counters {
+			if counter.suppressed > 0 {
+				lines = append(lines, suppressed{key: key, n: counter.suppressed})
+				counter.suppressed = 0
+			}
+			if now.Sub(counter.window) >= c.interval {
+				delete(c.counters, key)
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	for _, line := range lines {
+		ent := zapcore.Entry{Level: zapcore.WarnLevel, Time: now, Message: suppressedMessage}
+		if ce := t.report.Check(ent, nil); ce != nil {
+			fields := []zap.Field{
+				zap.String("suppressedLevel", line.key.level.String()),
+				zap.String("suppressedMessage", line.key.message),
+				zap.Uint64("suppressed", line.n),
+			}
+			if line.key.key != "" {
+				fields = append(fields, zap.String("suppressedKey", line.key.key))
+			}
+			ce.ErrorOutput = stderr
+			ce.Write(fields...)
+		}
+	}
+}
+
+// Close stops reporting and logs the last suppressed counts
+func (t *throttle) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		t.wg.Wait()
+		t.flush()
+	})
+	return nil
+}
+
+type throttleCore struct {
+	zapcore.Core
+	t *throttle
+}
+
+func (c *throttleCore) With(fields []zapcore.Field) zapcore.Core {
+	return &throttleCore{Core: c.Core.With(fields), t: c.t}
+}
+
+func (c *throttleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write passes the line to the wrapped core when it isn't suppressed, checking it
+// again so levels of teed sinks apply
+func (c *throttleCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.t.check(ent, fields) {
+		return nil
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.ErrorOutput = stderr
+		ce.Write(fields...)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newThrottledLogger(t *testing.T, opts ThrottleOptions, now *time.Time) (*zap.Logger, *throttle, *bytes.Buffer) {
+	enc, err := getEncoder(EncodingJSON, EncoderKeys{Time: "-", Caller: "-"})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	core, closer, err := newThrottleCore(zapcore.NewCore(enc, zapcore.AddSync(&buf), zapcore.DebugLevel), opts)
+	assert.Nil(t, err)
+
+	th := closer.(*throttle)
+	th.now = func() time.Time { return *now }
+	return zap.New(core), th, &buf
+}
+
+func TestSampling(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	l, th, buf := newThrottledLogger(t, ThrottleOptions{
+		Sampling:       SamplingOptions{First: 2, Thereafter: 3},
+		ReportInterval: time.Hour,
+	}, &now)
+
+	for i := 0; i < 10; i++ {
+		l.Info("repeated")
+	}
+	l.Info("other")
+	//lines 1, 2, 5 and 8 of the interval
+	assert.Equal(t, 4, strings.Count(buf.String(), `"msg":"repeated"`))
+	assert.Equal(t, 1, strings.Count(buf.String(), `"msg":"other"`))
+
+	now = now.Add(time.Second)
+	buf.Reset()
+	l.Info("repeated")
+	l.Info("repeated")
+	assert.Equal(t, 2, strings.Count(buf.String(), `"msg":"repeated"`))
+
+	buf.Reset()
+	th.Close()
+	assert.Equal(t, `{"level":"warn","msg":"log lines suppressed","suppressedLevel":"info","suppressedMessage":"repeated","suppressed":6}`+"\n", buf.String())
+
+	//counters of past windows are forgotten once reported
+	assert.Len(t, th.samples, 1)
+}
+
+func TestSamplingKeyFields(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	l, _, buf := newThrottledLogger(t, ThrottleOptions{
+		Sampling:       SamplingOptions{First: 1},
+		ReportInterval: time.Hour,
+	}, &now)
+
+	//session and cache lines share the "|" message, they are sampled by _app_tag
+	for i := 0; i < 3; i++ {
+		l.Info("|", zap.String("_app_tag", "T1"))
+		l.Info("|", zap.String("_app_tag", "caching"))
+	}
+	assert.Equal(t, 1, strings.Count(buf.String(), `"_app_tag":"T1"`))
+	assert.Equal(t, 1, strings.Count(buf.String(), `"_app_tag":"caching"`))
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	l, th, buf := newThrottledLogger(t, ThrottleOptions{
+		RateLimit:      RateLimitOptions{Limit: 2, Interval: time.Minute, KeyFields: []string{"caller"}},
+		ReportInterval: time.Hour,
+	}, &now)
+
+	for i := 0; i < 5; i++ {
+		l.Error("redis-cache", zap.String("caller", "rcache.Get"), zap.Int("attempt", i))
+		l.Error("redis-cache", zap.String("caller", "rcache.Set"))
+		l.Warn("redis-cache", zap.String("caller", "rcache.Get"))
+	}
+	assert.Equal(t, 2, strings.Count(buf.String(), `"caller":"rcache.Get","attempt"`))
+	assert.Equal(t, 2, strings.Count(buf.String(), `"caller":"rcache.Set"`))
+	assert.Equal(t, 5, strings.Count(buf.String(), `"level":"warn"`))
+
+	assert.Panics(t, func() { l.Panic("redis-cache", zap.String("caller", "rcache.Get")) })
+	assert.Contains(t, buf.String(), `"level":"panic"`)
+
+	buf.Reset()
+	th.flush()
+	assert.Contains(t, buf.String(), `"suppressed":3,"suppressedKey":"rcache.Get"`)
+	assert.Contains(t, buf.String(), `"suppressed":3,"suppressedKey":"rcache.Set"`)
+
+	buf.Reset()
+	th.flush()
+	assert.Empty(t, buf.String())
+	th.Close()
+}
+
+func TestThrottleLogger(t *testing.T) {
+	l, err := New(Options{
+		Stdout:      true,
+		Throttle:    ThrottleOptions{RateLimit: RateLimitOptions{Limit: 1}},
+		TdrThrottle: ThrottleOptions{RateLimit: RateLimitOptions{Limit: 1, Level: "verbose"}},
+	})
+	assert.NotNil(t, err)
+	assert.Nil(t, l)
+
+	l, err = New(Options{
+		Stdout:      true,
+		TdrThrottle: ThrottleOptions{Sampling: SamplingOptions{First: 1}},
+	})
+	assert.Nil(t, err)
+	_, ok := l.(*zapLogger).loggerTdr.Core().(*throttleCore)
+	assert.True(t, ok)
+	_, ok = l.(*zapLogger).logger.Core().(*throttleCore)
+	assert.False(t, ok)
+	assert.Nil(t, l.Close())
+}
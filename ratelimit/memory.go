@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/armiariyan/bepkg/logger"
+)
+
+// sweepSize number of tracked keys after which idle keys are removed, at most once per window
+const sweepSize = 10000
+
+type memState struct {
+	//fixed window
+	count       int
+	windowStart time.Time
+	//sliding log
+	log []time.Time
+	//token bucket
+	tokens float64
+	last   time.Time
+}
+
+type memLimiter struct {
+	mu     sync.Mutex
+	cfg    Config
+	keys   map[string]*memState
+	swept  time.Time
+	logger logger.Logger
+}
+
+// NewMemory create in-memory limiter, limits are counted per instance only
+func NewMemory(cfg Config) (Limiter, error) {
+	if cfg.Limit <= 0 || cfg.Window <= 0 {
+		return nil, ErrInvalidConfig
+	}
+	return &memLimiter{
+		cfg:  cfg,
+		keys: make(map[string]*memState),
+	}, nil
+}
+
+func (m *memLimiter) SetLogger(l logger.Logger) {
+	m.logger = l
+}
+
+// Allow checks and counts a request of key
+func (m *memLimiter) Allow(key string) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if len(m.keys) >= sweepSize && now.Sub(m.swept) >= m.cfg.Window {
+		m.sweep(now)
+		m.swept = now
+	}
+
+	st, ok := m.keys[key]
+	if !ok {
+		st = &memState{tokens: float64(m.cfg.Limit), last: now}
+		m.keys[key] = st
+	}
+
+	switch m.cfg.Algorithm {
+	case SlidingLog:
+		return m.slidingLog(st, now), nil
+	case TokenBucket:
+		return m.tokenBucket(st, now), nil
+	}
+	return m.fixedWindow(st, now), nil
+}
+
+func (m *memLimiter) fixedWindow(st *memState, now time.Time) Result {
+	if now.Sub(st.windowStart) >= m.cfg.Window {
+		st.windowStart = now
+		st.count = 0
+	}
+	st.count++
+
+	if st.count <= m.cfg.Limit {
+		return Result{Allowed: true, Remaining: m.cfg.Limit - st.count}
+	}
+	return Result{RetryAfter: st.windowStart.Add(m.cfg.Window).Sub(now)}
+}
+
+func (m *memLimiter) slidingLog(st *memState, now time.Time) Result {
+	i := 0
+	for i < len(st.log) && now.Sub(st.log[i]) >= m.cfg.Window {
+		i++
+	}
+	st.log = st.log[i:]
+
+	if len(st.log) < m.cfg.Limit {
+		st.log = append(st.log, now)
+		return Result{Allowed: true, Remaining: m.cfg.Limit - len(st.log)}
+	}
+	return Result{RetryAfter: st.log[0].Add(m.cfg.Window).Sub(now)}
+}
+
+func (m *memLimiter) tokenBucket(st *memState, now time.Time) Result {
+	capacity := float64(m.cfg.Limit)
+	rate := capacity / float64(m.cfg.Window)
+
+	st.tokens = math.Min(capacity, st.tokens+float64(now.Sub(st.last))*rate)
+	st.last = now
+
+	if st.tokens >= 1 {
+		st.tokens--
+		return Result{Allowed: true, Remaining: int(st.tokens)}
+	}
+	return Result{RetryAfter: time.Duration(math.Ceil((1 - st.tokens) / rate))}
+}
+
+// sweep removes keys idle for longer than a window.
+// caller must hold the lock
+func (m *memLimiter) sweep(now time.Time) {
+	for key, st := range m.keys {
+		last := st.windowStart
+		if st.last.After(last) {
+			last = st.last
+		}
+		if n := len(st.log); n > 0 && st.log[n-1].After(last) {
+			last = st.log[n-1]
+		}
+		if now.Sub(last) >= m.cfg.Window {
+			delete(m.keys, key)
+		}
+	}
+}
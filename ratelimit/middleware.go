@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	Response "github.com/armiariyan/bepkg/response"
+	Session "github.com/armiariyan/bepkg/session"
+)
+
+// appSession echo context key of the application session, see vo.AppSession
+const appSession = "App_Session"
+
+// KeyFunc extracts the rate limit key of a request
+type KeyFunc func(c echo.Context) string
+
+// SrcIPKey keys requests by session.Session.SrcIP, falling back to echo RealIP
+// when the session is not set yet
+func SrcIPKey(c echo.Context) string {
+	switch session := c.Get(appSession).(type) {
+	case *Session.Session:
+		if session.SrcIP != "" {
+			return session.SrcIP
+		}
+	case Session.Session:
+		if session.SrcIP != "" {
+			return session.SrcIP
+		}
+	}
+	return c.RealIP()
+}
+
+// Middleware rejects requests exceeding the limiter with http 429 and the
+// standard response envelope. keyFunc defaults to SrcIPKey.
+func Middleware(limiter Limiter, keyFunc KeyFunc) echo.MiddlewareFunc {
+	if keyFunc == nil {
+		keyFunc = SrcIPKey
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			res, err := limiter.Allow(keyFunc(c))
+			if err != nil || res.Allowed {
+				// fail open, an unavailable limiter must not take the service down
+				return next(c)
+			}
+
+			response := Response.CreateResponse(Response.ErrorTooManyRequest, "Too many requests", struct{}{})
+
+			switch session := c.Get(appSession).(type) {
+			case *Session.Session:
+				session.T4(response)
+			case Session.Session:
+				session.T4(response)
+			}
+
+			retryAfter := int(math.Ceil(res.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			return c.JSON(http.StatusTooManyRequests, response)
+		}
+	}
+}
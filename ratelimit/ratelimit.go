@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+
+	"github.com/armiariyan/bepkg/cache"
+	"github.com/armiariyan/bepkg/logger"
+)
+
+// Algorithm rate limiting algorithm
+type Algorithm int
+
+const (
+	//FixedWindow counts requests in consecutive windows, default algorithm
+	FixedWindow Algorithm = iota
+	//SlidingLog remembers every request timestamp of the last window
+	SlidingLog
+	//TokenBucket refills Limit tokens per Window allowing bursts up to Limit
+	TokenBucket
+)
+
+// Config set config for limiter
+type Config struct {
+	Algorithm Algorithm
+	//Maximum requests allowed per Window
+	Limit  int
+	Window time.Duration
+	//Prefix of keys stored in the cache, default is "ratelimit:"
+	Prefix string
+}
+
+// Result of a rate limit check
+type Result struct {
+	Allowed   bool
+	Remaining int
+	//Time to wait before the next request may be allowed, zero if allowed
+	RetryAfter time.Duration
+}
+
+// ErrInvalidConfig returned by New and NewMemory when Limit or Window isn't positive
+var ErrInvalidConfig = errors.New("ratelimit: limit and window must be positive")
+
+// Limiter rate limiter interface
+type Limiter interface {
+	SetLogger(l logger.Logger)
+	Allow(key string) (Result, error)
+}
+
+// New create limiter running atomically on redis when kv is a cache.Scripter,
+// while redis is unreachable requests are limited by an in-memory limiter of this instance.
+// Other backends get the in-memory limiter only.
+func New(kv cache.Keyval, cfg Config) (Limiter, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "ratelimit:"
+	}
+
+	local, err := NewMemory(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if scripter, ok := kv.(cache.Scripter); ok {
+		return &redisLimiter{
+			scripter: scripter,
+			cfg:      cfg,
+			fallback: local,
+		}, nil
+	}
+	return local, nil
+}
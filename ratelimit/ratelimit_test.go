@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/armiariyan/bepkg/cache"
+	Logger "github.com/armiariyan/bepkg/logger"
+	Session "github.com/armiariyan/bepkg/session"
+)
+
+func TestMemoryAlgorithms(t *testing.T) {
+	for _, algorithm := range []Algorithm{FixedWindow, SlidingLog, TokenBucket} {
+		l, err := NewMemory(Config{Algorithm: algorithm, Limit: 3, Window: 100 * time.Millisecond})
+		assert.Nil(t, err)
+
+		for i := 0; i < 3; i++ {
+			res, err := l.Allow("user1")
+			assert.Nil(t, err)
+			assert.True(t, res.Allowed, "algorithm %d request %d", algorithm, i)
+			assert.Equal(t, 2-i, res.Remaining)
+		}
+
+		res, _ := l.Allow("user1")
+		assert.False(t, res.Allowed, "algorithm %d", algorithm)
+		assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= 100*time.Millisecond)
+
+		res, _ = l.Allow("user2")
+		assert.True(t, res.Allowed)
+
+		time.Sleep(110 * time.Millisecond)
+		res, _ = l.Allow("user1")
+		assert.True(t, res.Allowed, "algorithm %d after window", algorithm)
+	}
+}
+
+type failingScripter struct {
+	cache.Keyval
+}
+
+func (failingScripter) Eval(rcv interface{}, script *cache.Script, keys []string, args ...string) error {
+	return errors.New("connection refused")
+}
+
+func TestRedisFallback(t *testing.T) {
+	l, err := New(failingScripter{Keyval: &cache.Mock{}}, Config{Limit: 1, Window: time.Minute})
+	assert.Nil(t, err)
+	_, ok := l.(*redisLimiter)
+	assert.True(t, ok)
+
+	res, err := l.Allow("user1")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+
+	res, _ = l.Allow("user1")
+	assert.False(t, res.Allowed)
+
+	l, _ = New(cache.NewMemory(cache.MemoryConfig{}), Config{Limit: 1, Window: time.Minute})
+	_, ok = l.(*memLimiter)
+	assert.True(t, ok)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, cfg := range []Config{{}, {Limit: 1}, {Window: time.Minute}, {Limit: -1, Window: time.Minute}} {
+		_, err := NewMemory(cfg)
+		assert.Equal(t, ErrInvalidConfig, err)
+		_, err = New(&cache.Mock{}, cfg)
+		assert.Equal(t, ErrInvalidConfig, err)
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	l, _ := NewMemory(Config{Limit: 1, Window: 50 * time.Millisecond})
+	m := l.(*memLimiter)
+	for i := 0; i < sweepSize; i++ {
+		m.Allow(strconv.Itoa(i))
+	}
+
+	//live keys are swept at most once per window
+	m.Allow("live")
+	swept := m.swept
+	assert.False(t, swept.IsZero())
+	m.Allow("again")
+	assert.Equal(t, swept, m.swept)
+
+	time.Sleep(60 * time.Millisecond)
+	m.Allow("after window")
+	assert.Len(t, m.keys, 1)
+}
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	l, err := NewMemory(Config{Limit: 1, Window: time.Minute})
+	assert.Nil(t, err)
+	h := Middleware(l, nil)(func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	log, err := Logger.New(Logger.Options{Stdout: true})
+	assert.Nil(t, err)
+
+	do := func(srcIP string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/otp", nil), rec)
+		c.Set(appSession, Session.New(log).SetSrcIP(srcIP))
+		assert.Nil(t, h(c))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, do("10.0.0.1").Code)
+
+	rec := do("10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"data":{},"status":"96","message":"Too many requests"}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusOK, do("10.0.0.2").Code)
+}
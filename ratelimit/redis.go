@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/armiariyan/bepkg/cache"
+	"github.com/armiariyan/bepkg/logger"
+)
+
+// Scripts reply {allowed, remaining, retry after in milliseconds}.
+// Current time is passed by the caller in milliseconds.
+var (
+	//KEYS[1] counter, ARGV[1] limit, ARGV[2] window
+	fixedWindowScript = cache.NewScript(1, `
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+local limit = tonumber(ARGV[1])
+if count <= limit then
+	return {1, limit - count, 0}
+end
+return {0, 0, redis.call("PTTL", KEYS[1])}`)
+
+	//KEYS[1] sorted set of request timestamps, ARGV[1] limit, ARGV[2] window, ARGV[3] now, ARGV[4] member
+	slidingLogScript = cache.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], window)
+	return {1, limit - count - 1, 0}
+end
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+return {0, 0, tonumber(oldest[2]) + window - now}`)
+
+	//KEYS[1] hash of tokens and last refill, ARGV[1] capacity, ARGV[2] window, ARGV[3] now
+	tokenBucketScript = cache.NewScript(1, `
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local rate = capacity / window
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], window)
+return {allowed, math.floor(tokens), retry}`)
+)
+
+type redisLimiter struct {
+	scripter cache.Scripter
+	cfg      Config
+	fallback Limiter
+	logger   logger.Logger
+}
+
+func (r *redisLimiter) SetLogger(l logger.Logger) {
+	r.logger = l
+	r.fallback.SetLogger(l)
+}
+
+func (r *redisLimiter) logError(message interface{}) {
+	if r.logger != nil {
+		r.logger.Error("ratelimit",
+			logger.ToField("caller", logger.Caller(2)),
+			logger.ToField("message", message),
+		)
+	}
+}
+
+// Allow checks and counts a request of key
+func (r *redisLimiter) Allow(key string) (res Result, err error) {
+	window := strconv.FormatInt(r.cfg.Window.Milliseconds(), 10)
+	limit := strconv.Itoa(r.cfg.Limit)
+	now := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	keys := []string{r.cfg.Prefix + key}
+
+	var rcv []int64
+	switch r.cfg.Algorithm {
+	case SlidingLog:
+		member := make([]byte, 8)
+		if _, err = rand.Read(member); err != nil {
+			return
+		}
+		err = r.scripter.Eval(&rcv, slidingLogScript, keys, limit, window, now, now+"-"+hex.EncodeToString(member))
+	case TokenBucket:
+		err = r.scripter.Eval(&rcv, tokenBucketScript, keys, limit, window, now)
+	default:
+		err = r.scripter.Eval(&rcv, fixedWindowScript, keys, limit, window)
+	}
+
+	if err == nil && len(rcv) != 3 {
+		err = fmt.Errorf("unexpected rate limit reply %v", rcv)
+	}
+	if err != nil {
+		r.logError(fmt.Sprintf("%s %s", key, err.Error()))
+		return r.fallback.Allow(key)
+	}
+
+	res = Result{
+		Allowed:    rcv[0] == 1,
+		Remaining:  int(rcv[1]),
+		RetryAfter: time.Duration(rcv[2]) * time.Millisecond,
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/armiariyan/bepkg/cache"
+	"github.com/armiariyan/bepkg/logger"
+)
+
+// unreachable fails the test when the limiter falls back to memory
+type unreachable struct {
+	t *testing.T
+}
+
+func (u unreachable) SetLogger(l logger.Logger) {}
+
+func (u unreachable) Allow(key string) (Result, error) {
+	u.t.Fatal("script failed, limiter fell back to memory")
+	return Result{}, nil
+}
+
+// TestRedisScripts runs the scripts on the redis server of CACHETEST_REDIS,
+// e.g. CACHETEST_REDIS=redis://127.0.0.1:6379/15
+func TestRedisScripts(t *testing.T) {
+	url := os.Getenv("CACHETEST_REDIS")
+	if url == "" {
+		t.Skip("CACHETEST_REDIS not set")
+	}
+	kv, err := cache.NewRedis(cache.Config{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kv.Close()
+
+	prefix := "ratelimit-test:" + strconv.FormatInt(time.Now().UnixNano(), 36) + ":"
+	for _, algorithm := range []Algorithm{FixedWindow, SlidingLog, TokenBucket} {
+		l, err := New(kv, Config{Algorithm: algorithm, Limit: 3, Window: 200 * time.Millisecond, Prefix: prefix})
+		assert.Nil(t, err)
+		l.(*redisLimiter).fallback = unreachable{t: t}
+
+		key := strconv.Itoa(int(algorithm))
+		for i := 0; i < 3; i++ {
+			res, err := l.Allow(key)
+			assert.Nil(t, err)
+			assert.True(t, res.Allowed, "algorithm %d request %d", algorithm, i)
+			assert.Equal(t, 2-i, res.Remaining)
+		}
+
+		res, _ := l.Allow(key)
+		assert.False(t, res.Allowed, "algorithm %d", algorithm)
+		assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= 200*time.Millisecond, "algorithm %d retry after %v", algorithm, res.RetryAfter)
+
+		time.Sleep(250 * time.Millisecond)
+		res, _ = l.Allow(key)
+		assert.True(t, res.Allowed, "algorithm %d after window", algorithm)
+
+		ttl, err := kv.TTL(prefix + key)
+		assert.Nil(t, err)
+		assert.True(t, ttl > 0 && ttl <= 200*time.Millisecond, "algorithm %d ttl %v", algorithm, ttl)
+	}
+}
@@ -2,6 +2,7 @@ package response
 
 const (
 	SuccessCode         string = "00"
+	ErrorTooManyRequest string = "96"
 	ErrorInvalidRequest string = "97"
 	ErrorInvalidJson    string = "98"
 	GeneralError        string = "99"
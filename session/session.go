@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -23,6 +24,7 @@ type Session struct {
 	SrcIP, URL, Method      string
 	Header, Request         interface{}
 	ErrorMessage            string
+	Context                 context.Context
 }
 
 func New(logger Logger.Logger) *Session {
@@ -30,6 +32,7 @@ func New(logger Logger.Logger) *Session {
 		RequestTime: time.Now(),
 		Logger:      logger,
 		Map:         Map.New(),
+		Context:     context.Background(),
 	}
 }
 
@@ -88,6 +91,13 @@ func (session *Session) SetErrorMessage(errorMessage string) *Session {
 	return session
 }
 
+// SetContext set request context, e.g. echo.Context.Request().Context(),
+// so deadline and cancellation are respected by context-aware clients
+func (session *Session) SetContext(ctx context.Context) *Session {
+	session.Context = ctx
+	return session
+}
+
 func (session *Session) Get(key string) (data interface{}, err error) {
 	data, ok := session.Map.Get(key)
 	if !ok {
@@ -100,24 +110,38 @@ func (session *Session) Put(key string, data interface{}) {
 	session.Map.Set(key, data)
 }
 
-func (session *Session) T1(message ...interface{}) {
-	session.Logger.Info("|",
-		zap.String("_app_tag", "T1"),
+// logFields returns the request fields of every session line, the message is redacted
+func (session *Session) logFields(tag string, message ...interface{}) []zap.Field {
+	return []zap.Field{
+		zap.String("_app_tag", tag),
 		zap.String("_app_thread_id", session.ThreadID),
 		zap.String("_app_method", session.Method),
 		zap.String("_app_uri", session.URL),
-		zap.String("_message", formatResponse(message...)),
-	)
+		zap.String("_message", formatResponse(session.Logger.Redactor(), message...)),
+	}
 }
 
-func (session *Session) T2(message ...interface{}) time.Time {
-	session.Logger.Info("|",
-		zap.String("_app_tag", "T2"),
-		zap.String("_app_thread_id", session.ThreadID),
+// LogContext returns the session context carrying the thread id and the logger scoped to the
+// request method and uri, pass it to repositories and clients logging with logger.FromContext
+func (session *Session) LogContext() context.Context {
+	//sessions not created by New have no context
+	parent := session.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx := Logger.ContextWithThreadID(parent, session.ThreadID)
+	return Logger.WithContext(ctx, session.Logger.With(
 		zap.String("_app_method", session.Method),
 		zap.String("_app_uri", session.URL),
-		zap.String("_message", formatResponse(message...)),
-	)
+	))
+}
+
+func (session *Session) T1(message ...interface{}) {
+	session.Logger.Info("|", session.logFields("T1", message...)...)
+}
+
+func (session *Session) T2(message ...interface{}) time.Time {
+	session.Logger.Info("|", session.logFields("T2", message...)...)
 
 	return time.Now()
 }
@@ -125,28 +149,18 @@ func (session *Session) T2(message ...interface{}) time.Time {
 func (session *Session) T3(startProcessTime time.Time, message ...interface{}) {
 	stop := time.Now()
 
-	session.Logger.Info("|",
-		zap.String("_app_tag", "T3"),
-		zap.String("_app_thread_id", session.ThreadID),
-		zap.String("_app_method", session.Method),
-		zap.String("_app_uri", session.URL),
-		zap.String("_message", formatResponse(message...)),
+	session.Logger.Info("|", append(session.logFields("T3", message...),
 		zap.String("_process_time", fmt.Sprintf("%d ms", stop.Sub(startProcessTime).Nanoseconds()/1000000)),
-	)
+	)...)
 }
 
 func (session *Session) T4(message ...interface{}) {
 	stop := time.Now()
 	rt := stop.Sub(session.RequestTime).Nanoseconds() / 1000000
 
-	session.Logger.Info("|",
-		zap.String("_app_tag", "T4"),
-		zap.String("_app_thread_id", session.ThreadID),
-		zap.String("_app_method", session.Method),
-		zap.String("_app_uri", session.URL),
-		zap.String("_message", formatResponse(message...)),
+	session.Logger.Info("|", append(session.logFields("T4", message...),
 		zap.String("_response_time", fmt.Sprintf("%d ms", rt)),
-	)
+	)...)
 
 	session.Logger.TDR(Logger.LogTdrModel{
 		AppName:        session.AppName,
@@ -158,7 +172,7 @@ func (session *Session) T4(message ...interface{}) {
 		Path:           session.URL,
 		Header:         session.Header,
 		Request:        session.Request,
-		Response:       formatResponse(message...),
+		Response:       formatResponse(nil, message...), //redacted by TDR
 		Error:          session.ErrorMessage,
 		ThreadID:       session.ThreadID,
 		AdditionalData: session.Map,
@@ -166,36 +180,26 @@ func (session *Session) T4(message ...interface{}) {
 }
 
 func (session *Session) Info(message ...interface{}) {
-	session.Logger.Info("|",
-		zap.String("_app_tag", "INFO"),
-		zap.String("_app_thread_id", session.ThreadID),
-		zap.String("_app_method", session.Method),
-		zap.String("_app_uri", session.URL),
-		zap.String("_message", formatResponse(message...)),
-	)
+	session.Logger.Info("|", session.logFields("INFO", message...)...)
 }
 
 func (session *Session) Error(message ...interface{}) {
-	session.Logger.Error("|",
-		zap.String("_app_tag", "ERROR"),
-		zap.String("_app_thread_id", session.ThreadID),
-		zap.String("_app_method", session.Method),
-		zap.String("_app_uri", session.URL),
-		zap.String("_message", formatResponse(message...)),
-	)
+	session.Logger.Error("|", session.logFields("ERROR", message...)...)
 }
 
 var json = JsonIter.ConfigCompatibleWithStandardLibrary
 
-func formatResponse(message ...interface{}) string {
+// formatResponse concatenates the messages, each one redacted by r
+func formatResponse(r *Logger.Redactor, message ...interface{}) string {
 	sb := strings.Builder{}
 
 	for _, msg := range message {
 		var m []byte
 		if reflect.ValueOf(msg).Kind().String() == "string" {
-			m = []byte(msg.(string))
+			m = []byte(r.RedactString(msg.(string)))
 		} else {
 			m, _ = json.Marshal(msg)
+			m = []byte(r.RedactString(string(m)))
 		}
 
 		sb.Write(m)